@@ -85,6 +85,72 @@ func (i *Include) String() string {
 		i.Source, i.ConditionType, i.Function))
 }
 
+// IncludeDir represents the inclusion of every rule-file within
+// a directory.
+//
+// This is produced by the parser by include_dir statements.
+type IncludeDir struct {
+	// Node is our parent object.
+	Node
+
+	// Source holds the directory to include.
+	Source Object
+
+	// ConditionType holds "if" or "unless" if this inclusion is to
+	// be executed conditionally.
+	ConditionType string
+
+	// Function holds a function to call, if this is a conditional
+	// action.
+	Function Funcall
+}
+
+// String turns an IncludeDir object into a useful string.
+func (i *IncludeDir) String() string {
+	if i == nil {
+		return "<nil>"
+	}
+	if i.ConditionType == "" {
+		return (fmt.Sprintf("IncludeDir{ Source:%s }", i.Source))
+	}
+	return (fmt.Sprintf("IncludeDir{ Source:%s  ConditionType:%s Condition:%s}",
+		i.Source, i.ConditionType, i.Function))
+}
+
+// Foreach represents a loop, which repeats the statements within its
+// body once for each of the given values.
+//
+// This is produced by the parser by foreach statements.
+type Foreach struct {
+	// Node is our parent object.
+	Node
+
+	// Variable is the name of the variable which is set to each
+	// value in turn.
+	Variable string
+
+	// Values holds the values to iterate over.
+	Values Array
+
+	// Body holds the statements to repeat.
+	Body []Node
+}
+
+// String turns a Foreach object into a useful string.
+func (f *Foreach) String() string {
+	if f == nil {
+		return "<nil>"
+	}
+
+	body := []string{}
+	for _, n := range f.Body {
+		body = append(body, n.String())
+	}
+
+	return fmt.Sprintf("Foreach{ Variable:%s Values:%s Body:[%s] }",
+		f.Variable, f.Values, strings.Join(body, ", "))
+}
+
 // Rule represents a parsed rule.
 type Rule struct {
 	// Node is our parent node.
@@ -96,12 +162,23 @@ type Rule struct {
 	// Name contains the name of the rule.
 	Name string
 
+	// Description contains the optional human-readable description
+	// of the rule.
+	Description string
+
 	// Triggered is true if this rule is only triggered by
 	// another rule notifying it.
 	//
 	// Triggered rules are ignored when processing our list.
 	Triggered bool
 
+	// AtExit is true if this rule should only be executed once
+	// all other rules have been processed.
+	//
+	// AtExit rules are executed even if an earlier rule failed,
+	// which makes them useful for cleanup.
+	AtExit bool
+
 	// Parameters contains the params supplied by the user.
 	//
 	// The keys will be strings, with the values being either
@@ -151,6 +228,11 @@ func (r *Rule) String() string {
 	// trip prefix
 	args = strings.TrimPrefix(args, ", ")
 
+	// add the description, if present
+	if r.Description != "" {
+		args += fmt.Sprintf(" Description:%s", r.Description)
+	}
+
 	if r.ConditionType == "" {
 		return fmt.Sprintf("Rule %s{%s}", r.Type, args)
 	}
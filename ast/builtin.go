@@ -5,15 +5,19 @@ package ast
 import (
 	"bufio"
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/sha1"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"math/rand"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,16 +26,33 @@ import (
 
 	"github.com/skx/marionette/environment"
 	"github.com/skx/marionette/file"
+	"gopkg.in/yaml.v3"
 )
 
 // BuiltIn is the signature of a built-in function
 type BuiltIn func(env *environment.Environment, args []string) (Object, error)
 
+// Function describes a built-in function, along with the number of
+// arguments it accepts.
+//
+// This allows function-calls to be validated before they are invoked.
+type Function struct {
+	// Fn is the function which is used to implement it.
+	Fn BuiltIn
+
+	// Min is the smallest number of arguments accepted.
+	Min int
+
+	// Max is the largest number of arguments accepted, or -1 if
+	// there is no upper limit.
+	Max int
+}
+
 // FUNCTIONS contains our list of built-in functions, as a map.
 //
-// The key is the name of the function, and the value is the pointer to the
+// The key is the name of the function, and the value describes the
 // function which is used to implement it.
-var FUNCTIONS map[string]BuiltIn
+var FUNCTIONS map[string]Function
 
 // FALSE is a global false-value, which simplifies our function returns
 var FALSE = &Boolean{Value: false}
@@ -39,7 +60,7 @@ var FALSE = &Boolean{Value: false}
 // TRUE is a global true-value, which simplifies our function returns.
 var TRUE = &Boolean{Value: true}
 
-// STDIN is where we read from in our `prompt` function
+// STDIN is where we read from in our `prompt` and `confirm` functions
 var STDIN *bufio.Reader
 
 // init is called on startup, and creates the FUNCTIONS map which will
@@ -47,37 +68,53 @@ var STDIN *bufio.Reader
 func init() {
 
 	// create the map to hold function-references
-	FUNCTIONS = make(map[string]BuiltIn)
+	FUNCTIONS = make(map[string]Function)
 
 	// Populate it.
-	FUNCTIONS["contains"] = fnContains
-	FUNCTIONS["empty"] = fnEmpty
-	FUNCTIONS["equal"] = fnEqual
-	FUNCTIONS["equals"] = fnEqual // duplicate
-	FUNCTIONS["exists"] = fnExists
-	FUNCTIONS["failure"] = fnFailure
-	FUNCTIONS["field"] = fnField
-	FUNCTIONS["gt"] = fnGt
-	FUNCTIONS["gte"] = fnGte
-	FUNCTIONS["len"] = fnLen
-	FUNCTIONS["lower"] = fnLower
-	FUNCTIONS["lt"] = fnLt
-	FUNCTIONS["lte"] = fnLte
-	FUNCTIONS["matches"] = fnMatches
-	FUNCTIONS["md5"] = fnMD5Sum // duplicate
-	FUNCTIONS["md5sum"] = fnMD5Sum
-	FUNCTIONS["nonempty"] = fnNonEmpty
-	FUNCTIONS["on_path"] = fnOnPath
-	FUNCTIONS["prompt"] = fnPrompt
-	FUNCTIONS["rand"] = fnRandom
-	FUNCTIONS["set"] = fnNonEmpty // duplicate
-	FUNCTIONS["sha1"] = fnSha1Sum // duplicate
-	FUNCTIONS["sha1sum"] = fnSha1Sum
-	FUNCTIONS["success"] = fnSuccess
-	FUNCTIONS["unset"] = fnEmpty // duplicate
-	FUNCTIONS["upper"] = fnUpper
-	FUNCTIONS["newer"] = fnNewer
-	FUNCTIONS["older"] = fnOlder
+	FUNCTIONS["all_on_path"] = Function{Fn: fnAllOnPath, Min: 1, Max: -1}
+	FUNCTIONS["and"] = Function{Fn: fnAnd, Min: 1, Max: -1}
+	FUNCTIONS["confirm"] = Function{Fn: fnConfirm, Min: 1, Max: 1}
+	FUNCTIONS["contains"] = Function{Fn: fnContains, Min: 2, Max: 2}
+	FUNCTIONS["empty"] = Function{Fn: fnEmpty, Min: 1, Max: 1}
+	FUNCTIONS["equal"] = Function{Fn: fnEqual, Min: 2, Max: 2}
+	FUNCTIONS["equals"] = Function{Fn: fnEqual, Min: 2, Max: 2} // duplicate
+	FUNCTIONS["exists"] = Function{Fn: fnExists, Min: 1, Max: 1}
+	FUNCTIONS["expand"] = Function{Fn: fnExpand, Min: 1, Max: 1}
+	FUNCTIONS["failure"] = Function{Fn: fnFailure, Min: 1, Max: 1}
+	FUNCTIONS["field"] = Function{Fn: fnField, Min: 2, Max: 2}
+	FUNCTIONS["filesize"] = Function{Fn: fnFilesize, Min: 1, Max: 1}
+	FUNCTIONS["glob_count"] = Function{Fn: fnGlobCount, Min: 1, Max: 1}
+	FUNCTIONS["gt"] = Function{Fn: fnGt, Min: 2, Max: 2}
+	FUNCTIONS["gte"] = Function{Fn: fnGte, Min: 2, Max: 2}
+	FUNCTIONS["is_dir"] = Function{Fn: fnIsDir, Min: 1, Max: 1}
+	FUNCTIONS["is_file"] = Function{Fn: fnIsFile, Min: 1, Max: 1}
+	FUNCTIONS["json_get"] = Function{Fn: fnJSONGet, Min: 2, Max: 2}
+	FUNCTIONS["len"] = Function{Fn: fnLen, Min: 1, Max: 1}
+	FUNCTIONS["lower"] = Function{Fn: fnLower, Min: 1, Max: 1}
+	FUNCTIONS["lt"] = Function{Fn: fnLt, Min: 2, Max: 2}
+	FUNCTIONS["lte"] = Function{Fn: fnLte, Min: 2, Max: 2}
+	FUNCTIONS["matches"] = Function{Fn: fnMatches, Min: 2, Max: 2}
+	FUNCTIONS["md5"] = Function{Fn: fnMD5Sum, Min: 1, Max: 1} // duplicate
+	FUNCTIONS["md5sum"] = Function{Fn: fnMD5Sum, Min: 1, Max: 1}
+	FUNCTIONS["mode"] = Function{Fn: fnMode, Min: 1, Max: 1}
+	FUNCTIONS["nonempty"] = Function{Fn: fnNonEmpty, Min: 1, Max: 1}
+	FUNCTIONS["not"] = Function{Fn: fnNot, Min: 1, Max: 1}
+	FUNCTIONS["on_path"] = Function{Fn: fnOnPath, Min: 1, Max: 1}
+	FUNCTIONS["or"] = Function{Fn: fnOr, Min: 1, Max: -1}
+	FUNCTIONS["output_equals"] = Function{Fn: fnOutputEquals, Min: 2, Max: 2}
+	FUNCTIONS["prompt"] = Function{Fn: fnPrompt, Min: 1, Max: 2}
+	FUNCTIONS["rand"] = Function{Fn: fnRandom, Min: 2, Max: 3}
+	FUNCTIONS["random_string"] = Function{Fn: fnRandomString, Min: 2, Max: 2}
+	FUNCTIONS["set"] = Function{Fn: fnNonEmpty, Min: 1, Max: 1} // duplicate
+	FUNCTIONS["sha1"] = Function{Fn: fnSha1Sum, Min: 1, Max: 1} // duplicate
+	FUNCTIONS["sha1sum"] = Function{Fn: fnSha1Sum, Min: 1, Max: 1}
+	FUNCTIONS["success"] = Function{Fn: fnSuccess, Min: 1, Max: 1}
+	FUNCTIONS["truthy"] = Function{Fn: fnTruthy, Min: 1, Max: 1}
+	FUNCTIONS["unset"] = Function{Fn: fnEmpty, Min: 1, Max: 1} // duplicate
+	FUNCTIONS["upper"] = Function{Fn: fnUpper, Min: 1, Max: 1}
+	FUNCTIONS["yaml_get"] = Function{Fn: fnYAMLGet, Min: 2, Max: 2}
+	FUNCTIONS["newer"] = Function{Fn: fnNewer, Min: 2, Max: 2}
+	FUNCTIONS["older"] = Function{Fn: fnOlder, Min: 2, Max: 2}
 
 	STDIN = bufio.NewReader(os.Stdin)
 
@@ -87,6 +124,78 @@ func init() {
 // Now our built-in methods follow
 //
 
+// isTrue tests whether the given string is "truthy", using the same
+// rules as conditionals: the empty string, "false", and "0" are false
+// and everything else is true.
+func isTrue(str string) bool {
+	return str != "" && str != "false" && str != "0"
+}
+
+// fnAllOnPath returns true if every one of the given binaries can be
+// found on the users' PATH.
+func fnAllOnPath(env *environment.Environment, args []string) (Object, error) {
+
+	// At least one argument is required.
+	if len(args) < 1 {
+		return nil, fmt.Errorf("'all_on_path' requires at least one argument")
+	}
+
+	for _, arg := range args {
+		found, err := fnOnPath(env, []string{arg})
+		if err != nil {
+			return nil, err
+		}
+		if found != TRUE {
+			return FALSE, nil
+		}
+	}
+
+	return TRUE, nil
+}
+
+// fnAnd returns true if all of its arguments are true.
+func fnAnd(env *environment.Environment, args []string) (Object, error) {
+
+	// At least one argument is required.
+	if len(args) < 1 {
+		return nil, fmt.Errorf("'and' requires at least one argument")
+	}
+
+	for _, arg := range args {
+		if !isTrue(arg) {
+			return FALSE, nil
+		}
+	}
+
+	return TRUE, nil
+}
+
+// fnConfirm asks the user a yes/no question, and returns true if
+// they answered "y" or "yes".
+//
+// If there is no input available we return false, as that is the safe
+// choice.
+func fnConfirm(env *environment.Environment, args []string) (Object, error) {
+
+	// Only one argument is supported.
+	if len(args) != 1 {
+		return nil, fmt.Errorf("'confirm' requires a single argument")
+	}
+
+	// Show the question
+	fmt.Printf("%s [y/N]\n", args[0])
+
+	// Read a line of input, errors are ignored.
+	text, _ := STDIN.ReadString('\n')
+
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "y", "yes":
+		return TRUE, nil
+	}
+
+	return FALSE, nil
+}
+
 // fnContains returns true/false depending upon whether the first string
 // contains the second one.
 func fnContains(env *environment.Environment, args []string) (Object, error) {
@@ -147,6 +256,92 @@ func fnExists(env *environment.Environment, args []string) (Object, error) {
 	return FALSE, nil
 }
 
+// fnFilesize returns the size of the given file, in bytes.
+func fnFilesize(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'filesize': %d != 1", len(args))
+	}
+
+	size, err := file.Size(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Number{Value: size}, nil
+}
+
+// fnGlobCount returns the number of files which match the given pattern.
+func fnGlobCount(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'glob_count': %d != 1", len(args))
+	}
+
+	files, err := filepath.Glob(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Number{Value: int64(len(files))}, nil
+}
+
+// fnIsDir returns true if the given path is a directory.
+func fnIsDir(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'is_dir': %d != 1", len(args))
+	}
+
+	info, err := os.Stat(args[0])
+	if err == nil && info.IsDir() {
+		return TRUE, nil
+	}
+
+	return FALSE, nil
+}
+
+// fnIsFile returns true if the given path is a regular file.
+func fnIsFile(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'is_file': %d != 1", len(args))
+	}
+
+	info, err := os.Stat(args[0])
+	if err == nil && info.Mode().IsRegular() {
+		return TRUE, nil
+	}
+
+	return FALSE, nil
+}
+
+// fnMode returns the permissions of the given file, in octal.
+func fnMode(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'mode': %d != 1", len(args))
+	}
+
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &String{Value: fmt.Sprintf("%04o", info.Mode().Perm())}, nil
+}
+
+// fnExpand expands any variable references in the given string.
+//
+// Arguments have their variables expanded before we're called, so this
+// is a second pass; useful for expanding the output of a command.
+func fnExpand(env *environment.Environment, args []string) (Object, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args for 'expand': %d != 1", len(args))
+	}
+
+	if env == nil {
+		return nil, fmt.Errorf("'expand' requires an environment")
+	}
+
+	return &String{Value: env.ExpandVariables(args[0])}, nil
+}
+
 // fnFailure returns true if executing the given command fails.
 func fnFailure(env *environment.Environment, args []string) (Object, error) {
 
@@ -266,6 +461,69 @@ func fnGte(env *environment.Environment, args []string) (Object, error) {
 
 }
 
+// fnJSONGet returns the value at the given dotted path within a JSON
+// document, for example "data.items.0.name".
+//
+// Scalar values are returned as-is, while objects and arrays are
+// returned as JSON.
+func fnJSONGet(env *environment.Environment, args []string) (Object, error) {
+
+	// Two arguments are required.
+	if len(args) != 2 {
+		return nil, fmt.Errorf("'json_get' requires two arguments")
+	}
+
+	// Parse the JSON, preserving the formatting of numbers.
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(args[0]))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("'json_get' failed to parse JSON: %s", err)
+	}
+
+	// Walk the path
+	cur := doc
+	for _, key := range strings.Split(args[1], ".") {
+
+		switch val := cur.(type) {
+		case map[string]interface{}:
+			next, ok := val[key]
+			if !ok {
+				return nil, fmt.Errorf("'json_get' path %s not found", args[1])
+			}
+			cur = next
+
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, fmt.Errorf("'json_get' path %s not found", args[1])
+			}
+			cur = val[idx]
+
+		default:
+			return nil, fmt.Errorf("'json_get' path %s not found", args[1])
+		}
+	}
+
+	switch val := cur.(type) {
+	case nil:
+		return &String{Value: ""}, nil
+	case string:
+		return &String{Value: val}, nil
+	case json.Number:
+		return &String{Value: val.String()}, nil
+	case bool:
+		return &String{Value: strconv.FormatBool(val)}, nil
+	}
+
+	out, err := json.Marshal(cur)
+	if err != nil {
+		return nil, err
+	}
+	return &String{Value: string(out)}, nil
+}
+
 // fnLen returns the length of the given node.
 func fnLen(env *environment.Environment, args []string) (Object, error) {
 
@@ -383,6 +641,21 @@ func fnNonEmpty(env *environment.Environment, args []string) (Object, error) {
 	return FALSE, nil
 }
 
+// fnNot returns the inverse of its single argument.
+func fnNot(env *environment.Environment, args []string) (Object, error) {
+
+	// Only one argument is supported.
+	if len(args) != 1 {
+		return nil, fmt.Errorf("'not' requires a single argument")
+	}
+
+	if isTrue(args[0]) {
+		return FALSE, nil
+	}
+
+	return TRUE, nil
+}
+
 // fnOnPath returns true if the given binary can be found on the users' PATH
 func fnOnPath(env *environment.Environment, args []string) (Object, error) {
 
@@ -414,32 +687,98 @@ func fnOnPath(env *environment.Environment, args []string) (Object, error) {
 	return FALSE, nil
 }
 
+// fnOr returns true if any of its arguments are true.
+func fnOr(env *environment.Environment, args []string) (Object, error) {
+
+	// At least one argument is required.
+	if len(args) < 1 {
+		return nil, fmt.Errorf("'or' requires at least one argument")
+	}
+
+	for _, arg := range args {
+		if isTrue(arg) {
+			return TRUE, nil
+		}
+	}
+
+	return FALSE, nil
+}
+
+// fnOutputEquals returns true if the output of executing the given
+// command, with leading and trailing whitespace removed, matches the
+// expected value.
+//
+// Failing to execute the command, or the command exiting with a non-zero
+// exit-code, is an error.
+func fnOutputEquals(env *environment.Environment, args []string) (Object, error) {
+
+	// Two arguments are required.
+	if len(args) != 2 {
+		return nil, fmt.Errorf("'output_equals' requires two arguments")
+	}
+
+	// Build up the thing to run, using a shell so that
+	// we can handle pipes/redirection.
+	toRun := []string{"/bin/bash", "-c", args[0]}
+
+	// Run the command
+	cmd := exec.Command(toRun[0], toRun[1:]...)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("'output_equals' failed to run '%s': %s", args[0], err)
+	}
+
+	if strings.TrimSpace(string(out)) == args[1] {
+		return TRUE, nil
+	}
+
+	return FALSE, nil
+}
+
 // fnPrompt allows the user to be prompted for input.
 func fnPrompt(env *environment.Environment, args []string) (Object, error) {
 
-	// only one argument is allowed
-	if len(args) != 1 {
-		return nil, fmt.Errorf("wrong number of args for 'prompt': %d != 1", len(args))
+	// one or two arguments are allowed
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args for 'prompt': %d != 1 or 2", len(args))
 	}
 
-	// Show the prompt
+	// Show the prompt, along with any default value
 	arg := args[0]
-	fmt.Printf("%s\n", arg)
+	if len(args) == 2 {
+		fmt.Printf("%s [%s]\n", arg, args[1])
+	} else {
+		fmt.Printf("%s\n", arg)
+	}
 
 	// Read a line of input
 	//	reader := bufio.NewReader(os.Stdin)
 	text, err := STDIN.ReadString('\n')
+	text = strings.TrimSpace(text)
+
+	// If we have a default then EOF isn't an error, and we use
+	// the default if we read nothing - which means we can run
+	// without a human present.
+	if len(args) == 2 && (err == nil || err == io.EOF) {
+		if text == "" {
+			text = args[1]
+		}
+		return &String{Value: text}, nil
+	}
 
 	// No error? return it
 	if err == nil {
-		return &String{Value: strings.TrimSpace(text)}, nil
+		return &String{Value: text}, nil
 	}
 
 	// Return the error
 	return &String{Value: ""}, err
 }
 
-// Returns a random number between min/max values, with an optional seed value
+// fnRandom returns a random number between the min/max values, inclusive.
+//
+// If a seed value is given the result is deterministic.
 func fnRandom(env *environment.Environment, args []string) (Object, error) {
 
 	if len(args) != 2 && len(args) != 3 {
@@ -460,24 +799,75 @@ func fnRandom(env *environment.Environment, args []string) (Object, error) {
 		return nil, fmt.Errorf("max value is less than or equal to min. min: %d, max: %d", min, max)
 	}
 
+	// With a seed we're deterministic, which is useful for
+	// generating stable values - such as per-host cron times.
 	if len(args) == 3 {
-		seedString := args[2]
 		h := sha1.New()
-		_, err = io.WriteString(h, seedString)
+		_, err = io.WriteString(h, args[2])
 		if err != nil {
 			return nil, err
 		}
 		seed := binary.BigEndian.Uint64(h.Sum(nil))
-		rand.Seed(int64(seed))
-	} else {
-		rand.Seed(time.Now().UnixNano())
+
+		r := rand.New(rand.NewSource(int64(seed)))
+		val := r.Intn(max-min+1) + min
+		return &String{Value: strconv.Itoa(val)}, nil
 	}
 
-	val := rand.Intn(max-min) + min
+	// Otherwise use crypto/rand, so there's nothing to predict.
+	n, err := crand.Int(crand.Reader, big.NewInt(int64(max-min+1)))
+	if err != nil {
+		return nil, err
+	}
 
+	val := int(n.Int64()) + min
 	return &String{Value: strconv.Itoa(val)}, nil
 }
 
+// fnRandomString returns a random string of the given length, using
+// characters from the named charset.
+//
+// This is designed to be used for passwords and tokens, so we use
+// crypto/rand rather than math/rand.
+func fnRandomString(env *environment.Environment, args []string) (Object, error) {
+
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args for 'random_string': %d != 2", len(args))
+	}
+
+	length, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if length < 1 {
+		return nil, fmt.Errorf("length must be positive, got %d", length)
+	}
+
+	charsets := map[string]string{
+		"alnum": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+		"alpha": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+		"hex":   "0123456789abcdef",
+	}
+
+	chars, ok := charsets[args[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown charset '%s', valid choices are alnum, alpha, or hex", args[1])
+	}
+
+	max := big.NewInt(int64(len(chars)))
+	out := make([]byte, length)
+
+	for i := range out {
+		n, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = chars[n.Int64()]
+	}
+
+	return &String{Value: string(out)}, nil
+}
+
 // fnSha1Sum returns the SHA1 digest of the given input
 func fnSha1Sum(env *environment.Environment, args []string) (Object, error) {
 
@@ -532,6 +922,23 @@ func fnSuccess(env *environment.Environment, args []string) (Object, error) {
 	}
 }
 
+// fnTruthy returns true if the given value is a common way of saying
+// "yes": "true", "yes", "on", or "1", regardless of case.
+func fnTruthy(env *environment.Environment, args []string) (Object, error) {
+
+	// Only one argument is supported.
+	if len(args) != 1 {
+		return nil, fmt.Errorf("'truthy' requires a single argument")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "true", "yes", "on", "1":
+		return TRUE, nil
+	}
+
+	return FALSE, nil
+}
+
 // fnUpper converts the given node to upper-case.
 func fnUpper(env *environment.Environment, args []string) (Object, error) {
 
@@ -543,6 +950,71 @@ func fnUpper(env *environment.Environment, args []string) (Object, error) {
 	return &String{Value: strings.ToUpper(args[0])}, nil
 }
 
+// fnYAMLGet returns the scalar value at the given dotted path within a
+// YAML document, for example "server.ports.0".
+//
+// A missing path results in an empty string, but malformed YAML, or a
+// path which leads to something other than a scalar, is an error.
+func fnYAMLGet(env *environment.Environment, args []string) (Object, error) {
+
+	// Two arguments are required.
+	if len(args) != 2 {
+		return nil, fmt.Errorf("'yaml_get' requires two arguments")
+	}
+
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(args[0]), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("'yaml_get' failed to parse YAML: %s", err)
+	}
+
+	// An empty document has no values.
+	if len(doc.Content) < 1 {
+		return &String{Value: ""}, nil
+	}
+
+	// Walk the path
+	cur := doc.Content[0]
+	for _, key := range strings.Split(args[1], ".") {
+
+		if cur.Kind == yaml.AliasNode {
+			cur = cur.Alias
+		}
+
+		var next *yaml.Node
+
+		switch cur.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(cur.Content); i += 2 {
+				if cur.Content[i].Value == key {
+					next = cur.Content[i+1]
+					break
+				}
+			}
+
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(key)
+			if err == nil && idx >= 0 && idx < len(cur.Content) {
+				next = cur.Content[idx]
+			}
+		}
+
+		if next == nil {
+			return &String{Value: ""}, nil
+		}
+		cur = next
+	}
+
+	if cur.Kind == yaml.AliasNode {
+		cur = cur.Alias
+	}
+	if cur.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("'yaml_get' path %s is not a scalar value", args[1])
+	}
+
+	return &String{Value: cur.Value}, nil
+}
+
 func fnNewer(env *environment.Environment, args []string) (Object, error) {
 
 	// get modification times for only two existing filenames
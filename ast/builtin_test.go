@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/skx/marionette/environment"
 	"github.com/skx/marionette/file"
 )
 
@@ -17,84 +19,74 @@ func TestFunctionArgs(t *testing.T) {
 	// Ensure that all functions error without an argument
 	for name, fun := range FUNCTIONS {
 
-		_, err := fun(nil, []string{})
+		_, err := fun.Fn(nil, []string{})
 
 		if err == nil {
 			t.Fatalf("expected error invoking %s with no arguments", name)
 		}
 	}
 
-	// Ensure all functions abort with too many arguments
+	// Ensure all functions abort with too many arguments, unless
+	// they accept any number.
 	for name, fun := range FUNCTIONS {
-		_, err := fun(nil, []string{"one", "two", "three", "four"})
+		if fun.Max == -1 {
+			continue
+		}
+		_, err := fun.Fn(nil, []string{"one", "two", "three", "four"})
 
 		if err == nil {
 			t.Fatalf("expected error invoking %s with four arguments", name)
 		}
 	}
 
-	// number of args for each function; -1 to ignore arg check
-	m := make(map[string]int)
-	m["contains"] = 2
-	m["empty"] = 1
-	m["equal"] = 2
-	m["equals"] = 2
-	m["exists"] = 1
-	m["failure"] = 1
-	m["field"] = 2
-	m["gt"] = 2
-	m["gte"] = 2
-	m["len"] = 1
-	m["lower"] = 1
-	m["lt"] = 2
-	m["lte"] = 2
-	m["matches"] = 2
-	m["md5"] = 1
-	m["md5sum"] = 1
-	m["nonempty"] = 1
-	m["on_path"] = 1
-	m["prompt"] = 1
-	m["rand"] = 2
-	m["set"] = 1
-	m["sha1"] = 1
-	m["sha1sum"] = 1
-	m["success"] = 1
-	m["unset"] = 1
-	m["upper"] = 1
-	m["newer"] = -1
-	m["older"] = -1
+	// Functions which need real inputs, rather than our dummy
+	// values, when invoked with a valid number of arguments.
+	skip := map[string]bool{
+		"expand":        true,
+		"filesize":      true,
+		"json_get":      true,
+		"mode":          true,
+		"newer":         true,
+		"older":         true,
+		"output_equals": true,
+		"random_string": true,
+	}
+
 	one := []string{"1"}
 	two := []string{"23", "34"}
 
 	// Replace STDIN
 	old := STDIN
 
-	// Ensure that we can call functions with the right number
-	// of arguments.
+	// Ensure that we can call functions with the smallest valid
+	// number of arguments.
 	for name, fun := range FUNCTIONS {
 
+		if skip[name] {
+			continue
+		}
+
 		// Replace STDIN
 		STDIN = bufio.NewReader(strings.NewReader("STEVE\n"))
 
 		var err error
 
-		valid := m[name]
-
-		if valid == 1 {
+		switch fun.Min {
+		case 1:
 			t.Run(name, func(t *testing.T) {
-				_, err = fun(nil, one)
+				_, err = fun.Fn(nil, one)
 			})
 			if err != nil {
 				t.Fatalf("unexpected error with 1 arg:%s", err)
 			}
-		} else if valid == 2 {
+		case 2:
 			t.Run(name, func(t *testing.T) {
-				_, err = fun(nil, two)
+				_, err = fun.Fn(nil, two)
 			})
 			if err != nil {
 				t.Fatalf("unexpected error with 2 args for function '%s' with error: %s", name, err)
 			}
-		} else if valid != -1 {
+		default:
 			t.Fatalf("unhandled test-case for function '%s'", name)
 		}
 
@@ -103,6 +95,370 @@ func TestFunctionArgs(t *testing.T) {
 	STDIN = old
 }
 
+// TestArity ensures that the functions reject calls outside the
+// number of arguments they're registered with.
+func TestArity(t *testing.T) {
+
+	for name, fun := range FUNCTIONS {
+
+		if fun.Min < 1 {
+			t.Fatalf("function %s accepts no arguments", name)
+		}
+
+		// Too few arguments
+		args := make([]string, fun.Min-1)
+		_, err := fun.Fn(nil, args)
+		if err == nil {
+			t.Fatalf("expected error invoking %s with %d arguments", name, len(args))
+		}
+
+		// Too many arguments
+		if fun.Max != -1 {
+			args = make([]string, fun.Max+1)
+			_, err = fun.Fn(nil, args)
+			if err == nil {
+				t.Fatalf("expected error invoking %s with %d arguments", name, len(args))
+			}
+		}
+	}
+}
+
+// TestPromptDefault ensures that prompt falls back to the default
+// value when there is no input.
+func TestPromptDefault(t *testing.T) {
+
+	// Replace STDIN
+	old := STDIN
+
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"", "DEFAULT"},
+		{"\n", "DEFAULT"},
+		{"   \n", "DEFAULT"},
+		{"STEVE\n", "STEVE"},
+		{"STEVE", "STEVE"},
+	}
+
+	for _, test := range tests {
+
+		STDIN = bufio.NewReader(strings.NewReader(test.input))
+
+		out, err := fnPrompt(nil, []string{"What is your name?", "DEFAULT"})
+		if err != nil {
+			t.Fatalf("unexpected error with input %q: %s", test.input, err)
+		}
+		if out.(*String).Value != test.output {
+			t.Fatalf("wrong result for input %q: %s != %s", test.input, out, test.output)
+		}
+	}
+
+	// Without a default EOF is still an error
+	STDIN = bufio.NewReader(strings.NewReader(""))
+	_, err := fnPrompt(nil, []string{"What is your name?"})
+	if err == nil {
+		t.Fatalf("expected an error on EOF without a default")
+	}
+
+	STDIN = old
+}
+
+// TestConfirm ensures that confirm only returns true for an
+// affirmative answer.
+func TestConfirm(t *testing.T) {
+
+	// Replace STDIN
+	old := STDIN
+
+	tests := []struct {
+		input  string
+		output bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"  Yes  \n", true},
+		{"yes", true},
+		{"n\n", false},
+		{"yesterday\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+
+		STDIN = bufio.NewReader(strings.NewReader(test.input))
+
+		out, err := fnConfirm(nil, []string{"Delete config?"})
+		if err != nil {
+			t.Fatalf("unexpected error with input %q: %s", test.input, err)
+		}
+		if out.(*Boolean).Value != test.output {
+			t.Fatalf("wrong result for input %q: %s", test.input, out)
+		}
+	}
+
+	STDIN = old
+}
+
+// TestExpand ensures that expand performs a second pass of variable
+// expansion.
+func TestExpand(t *testing.T) {
+
+	env := environment.New()
+	env.Set("name", "steve")
+	env.Set("raw", "hello ${name}")
+
+	// The normal expansion is a single pass
+	raw := env.ExpandVariables("${raw}")
+	if raw != "hello ${name}" {
+		t.Fatalf("unexpected expansion: %s", raw)
+	}
+
+	// expand gives us another
+	out, err := fnExpand(env, []string{raw})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.(*String).Value != "hello steve" {
+		t.Fatalf("wrong expansion: %s", out)
+	}
+
+	// Without an environment we fail
+	_, err = fnExpand(nil, []string{raw})
+	if err == nil {
+		t.Fatalf("expected an error without an environment")
+	}
+}
+
+// TestFilesize ensures that we can find the size of a file.
+func TestFilesize(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/file"
+	err = ioutil.WriteFile(path, []byte("Hello, World"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	out, err := fnFilesize(nil, []string{path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.(*Number).Value != 12 {
+		t.Fatalf("wrong size: %s", out)
+	}
+
+	// Missing files are an error
+	_, err = fnFilesize(nil, []string{dir + "/missing"})
+	if err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
+
+// TestGlobCount ensures that we can count matching files.
+func TestGlobCount(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.conf", "b.conf", "c.txt"} {
+		err = ioutil.WriteFile(dir+"/"+name, []byte("data"), 0600)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		count   int64
+	}{
+		{dir + "/*.conf", 2},
+		{dir + "/*", 3},
+		{dir + "/*.missing", 0},
+	}
+
+	for _, test := range tests {
+		out, err := fnGlobCount(nil, []string{test.pattern})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if out.(*Number).Value != test.count {
+			t.Fatalf("wrong count for %s: %s != %d", test.pattern, out, test.count)
+		}
+	}
+
+	// Bogus patterns are an error
+	_, err = fnGlobCount(nil, []string{"[steve"})
+	if err == nil {
+		t.Fatalf("expected an error for a bogus pattern")
+	}
+}
+
+// TestIsDirFile ensures that we can tell files and directories apart.
+func TestIsDirFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/file"
+	err = ioutil.WriteFile(path, []byte("data"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		isReg bool
+	}{
+		{dir, true, false},
+		{path, false, true},
+		{dir + "/missing", false, false},
+	}
+
+	for _, test := range tests {
+
+		out, err := fnIsDir(nil, []string{test.path})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if out.(*Boolean).Value != test.isDir {
+			t.Fatalf("wrong result for is_dir(%s): %s", test.path, out)
+		}
+
+		out, err = fnIsFile(nil, []string{test.path})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if out.(*Boolean).Value != test.isReg {
+			t.Fatalf("wrong result for is_file(%s): %s", test.path, out)
+		}
+	}
+}
+
+// TestMode ensures that we can find the permissions of a file.
+func TestMode(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/file"
+	err = ioutil.WriteFile(path, []byte("data"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	for _, mode := range []os.FileMode{0600, 0644, 0755, 0777} {
+
+		err = os.Chmod(path, mode)
+		if err != nil {
+			t.Fatalf("failed to chmod: %s", err)
+		}
+
+		out, err := fnMode(nil, []string{path})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		expected := fmt.Sprintf("%04o", mode)
+		if out.(*String).Value != expected {
+			t.Fatalf("wrong mode %s != %s", out, expected)
+		}
+	}
+
+	// Missing files are an error
+	_, err = fnMode(nil, []string{dir + "/missing"})
+	if err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
+
+// TestRandom ensures that rand returns values within the range, and
+// that seeded values are repeatable.
+func TestRandom(t *testing.T) {
+
+	// Unseeded values should cover the whole range, inclusive.
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		out, err := fnRandom(nil, []string{"1", "3"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		seen[out.(*String).Value] = true
+	}
+	if len(seen) != 3 || !seen["1"] || !seen["2"] || !seen["3"] {
+		t.Fatalf("unexpected values generated: %v", seen)
+	}
+
+	// Seeded values are stable, and depend upon the seed.
+	a, err := fnRandom(nil, []string{"1", "1000000", "host1.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < 10; i++ {
+		b, _ := fnRandom(nil, []string{"1", "1000000", "host1.example.com"})
+		if a.String() != b.String() {
+			t.Fatalf("seeded value changed: %s != %s", a, b)
+		}
+	}
+
+	c, _ := fnRandom(nil, []string{"1", "1000000", "host2.example.com"})
+	if a.String() == c.String() {
+		t.Fatalf("different seeds gave the same value: %s", a)
+	}
+}
+
+// TestRandomString ensures our random strings have the right length
+// and contents.
+func TestRandomString(t *testing.T) {
+
+	tests := []struct {
+		charset string
+		valid   string
+	}{
+		{"alnum", "^[A-Za-z0-9]+$"},
+		{"alpha", "^[A-Za-z]+$"},
+		{"hex", "^[0-9a-f]+$"},
+	}
+
+	for _, test := range tests {
+
+		out, err := fnRandomString(nil, []string{"32", test.charset})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", test.charset, err)
+		}
+
+		str := out.(*String).Value
+		if len(str) != 32 {
+			t.Fatalf("wrong length for %s: %d", test.charset, len(str))
+		}
+		if !regexp.MustCompile(test.valid).MatchString(str) {
+			t.Fatalf("unexpected characters for %s: %s", test.charset, str)
+		}
+
+		// A second call should give a different result
+		again, _ := fnRandomString(nil, []string{"32", test.charset})
+		if again.(*String).Value == str {
+			t.Fatalf("repeated value for %s: %s", test.charset, str)
+		}
+	}
+}
+
 func TestFunctions(t *testing.T) {
 
 	// Replace STDIN
@@ -147,6 +503,175 @@ func TestFunctions(t *testing.T) {
 
 	tests := []TestCase{
 
+		TestCase{Name: "all_on_path",
+			Input:  []string{"sh"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "all_on_path",
+			Input:  []string{"sh", "sh"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "all_on_path",
+			Input:  []string{"sh", "this-binary-does-not-exist"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "and",
+			Input:  []string{"true", "1", "steve"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "and",
+			Input:  []string{"true", "false", "steve"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "and",
+			Input:  []string{"true", ""},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "or",
+			Input:  []string{"false", "0", ""},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "or",
+			Input:  []string{"false", "0", "yes"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "or",
+			Input:  []string{"true"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "not",
+			Input:  []string{"true"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "not",
+			Input:  []string{"0"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "not",
+			Input:  []string{""},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "origin"},
+			Output: &String{Value: "1.2.3.4"},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.items.0.name"},
+			Output: &String{Value: "steve"},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.items.0.age"},
+			Output: &String{Value: "42.50"},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.items.0.ok"},
+			Output: &String{Value: "true"},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.items.0.tags"},
+			Output: &String{Value: "[\"a\"]"},
+		},
+		TestCase{Name: "json_get",
+			Input:  []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.none"},
+			Output: &String{Value: ""},
+		},
+		TestCase{Name: "json_get",
+			Input: []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.items.1.name"},
+			Error: "not found",
+		},
+		TestCase{Name: "json_get",
+			Input: []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "data.missing"},
+			Error: "not found",
+		},
+		TestCase{Name: "json_get",
+			Input: []string{"{\"origin\": \"1.2.3.4\", \"data\": {\"items\": [{\"name\": \"steve\", \"age\": 42.50, \"ok\": true, \"tags\": [\"a\"]}], \"none\": null}}", "origin.foo"},
+			Error: "not found",
+		},
+		TestCase{Name: "json_get",
+			Input: []string{"{not json", "foo"},
+			Error: "failed to parse JSON",
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.name"},
+			Output: &String{Value: "web"},
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.ports.1"},
+			Output: &String{Value: "443"},
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.tls.enabled"},
+			Output: &String{Value: "true"},
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "copy.enabled"},
+			Output: &String{Value: "true"},
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.missing"},
+			Output: &String{Value: ""},
+		},
+		TestCase{Name: "yaml_get",
+			Input:  []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.ports.5"},
+			Output: &String{Value: ""},
+		},
+		TestCase{Name: "yaml_get",
+			Input: []string{"server:\n  name: web\n  ports:\n    - 80\n    - 443\n  tls: &tls\n    enabled: true\ncopy: *tls\n", "server.ports"},
+			Error: "not a scalar",
+		},
+		TestCase{Name: "yaml_get",
+			Input: []string{"server: [oops", "server"},
+			Error: "failed to parse YAML",
+		},
+		TestCase{Name: "output_equals",
+			Input:  []string{"echo '  hello  '", "hello"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "output_equals",
+			Input:  []string{"echo hello | tr a-z A-Z", "hello"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "output_equals",
+			Input: []string{"exit 3", ""},
+			Error: "failed to run",
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"true"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"YES"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"On"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"1"},
+			Output: &Boolean{Value: true},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"false"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"no"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"0"},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{""},
+			Output: &Boolean{Value: false},
+		},
+		TestCase{Name: "truthy",
+			Input:  []string{"steve"},
+			Output: &Boolean{Value: false},
+		},
+
 		TestCase{Name: "lt",
 			Input: []string{
 				"1",
@@ -406,6 +931,7 @@ func TestFunctions(t *testing.T) {
 			Input: []string{
 				"Foo",
 				"Bar",
+				"Baz",
 			},
 			Error: "wrong number of args",
 		},
@@ -422,7 +948,7 @@ func TestFunctions(t *testing.T) {
 				"100",
 				"hostname",
 			},
-			Output: &String{Value: "96"},
+			Output: &String{Value: "77"},
 		},
 		TestCase{Name: "rand",
 			Input: []string{
@@ -438,6 +964,27 @@ func TestFunctions(t *testing.T) {
 			},
 			Error: "strconv.Atoi",
 		},
+		TestCase{Name: "random_string",
+			Input: []string{
+				"steve",
+				"hex",
+			},
+			Error: "strconv.Atoi",
+		},
+		TestCase{Name: "random_string",
+			Input: []string{
+				"0",
+				"hex",
+			},
+			Error: "must be positive",
+		},
+		TestCase{Name: "random_string",
+			Input: []string{
+				"12",
+				"steve",
+			},
+			Error: "unknown charset",
+		},
 		TestCase{Name: "sha1sum",
 			Input: []string{
 				"secret",
@@ -577,7 +1124,7 @@ func TestFunctions(t *testing.T) {
 			}
 
 			// Call the function
-			ret, err := fun(nil, test.Input)
+			ret, err := fun.Fn(nil, test.Input)
 
 			// Got an error making the call
 			if err != nil {
@@ -158,7 +158,7 @@ func (f Funcall) Evaluate(env *environment.Environment) (string, error) {
 	log.Printf("[DEBUG] Invoking function - %s(%s)", f.Name, strings.Join(args, ","))
 
 	// Call the function, with the stringified arguments.
-	ret, err := fn(env, args)
+	ret, err := fn.Fn(env, args)
 	if err != nil {
 		return "", err
 	}
@@ -169,6 +169,44 @@ func (f Funcall) Evaluate(env *environment.Environment) (string, error) {
 	return ret.Evaluate(env)
 }
 
+// CheckArgs ensures that the function is defined, and that it has been
+// given a valid number of arguments.
+//
+// Any function-calls used as arguments are checked too.
+func (f Funcall) CheckArgs() error {
+
+	arity, ok := FUNCTIONS[f.Name]
+	if !ok {
+		return fmt.Errorf("function %s not defined", f.Name)
+	}
+
+	count := len(f.Args)
+	if count < arity.Min || (arity.Max != -1 && count > arity.Max) {
+		expected := fmt.Sprintf("%d", arity.Min)
+		if arity.Max == -1 {
+			expected = fmt.Sprintf("at least %d", arity.Min)
+		} else if arity.Max != arity.Min {
+			expected = fmt.Sprintf("%d to %d", arity.Min, arity.Max)
+		}
+		return fmt.Errorf("wrong number of args for '%s': %d, expected %s", f.Name, count, expected)
+	}
+
+	// Check any nested calls
+	for _, arg := range f.Args {
+		call, ok := arg.(Funcall)
+		if !ok {
+			continue
+		}
+
+		err := call.CheckArgs()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // String returns our object as a string.
 func (f Funcall) String() string {
 	args := ""
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// ANSI escape-sequences used to colorize our output.
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// colorWriter wraps a writer, colorizing each log-line which is written
+// to it based upon its content.
+type colorWriter struct {
+	out io.Writer
+}
+
+// Write colorizes the given line, and writes it to our destination.
+//
+// Errors are shown in red, warnings and skipped rules in yellow, and
+// changes in green.
+func (c *colorWriter) Write(p []byte) (int, error) {
+
+	color := ""
+	switch {
+	case bytes.Contains(p, []byte("[ERROR]")):
+		color = colorRed
+	case bytes.Contains(p, []byte("[WARN]")),
+		bytes.Contains(p, []byte("Skipping")):
+		color = colorYellow
+	case bytes.Contains(p, []byte("resulted in a change")):
+		color = colorGreen
+	}
+
+	if color == "" {
+		return c.out.Write(p)
+	}
+
+	// Color the line, leaving any trailing newline alone.
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	out := append([]byte(color), line...)
+	out = append(out, colorReset...)
+	out = append(out, p[len(line):]...)
+
+	_, err := c.out.Write(out)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// isTerminal returns true if the given file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor decides whether we should colorize our output, given the
+// value of the -color flag.
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	// Automatic; only colorize if we're writing to a terminal.
+	return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stderr)
+}
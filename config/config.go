@@ -14,6 +14,21 @@ type Config struct {
 	// CLI was started with the `-debug` flag present.
 	Debug bool
 
+	// KeepGoing is used to let the executor know that the marionette
+	// CLI was started with the `-keep-going` flag present, so rule
+	// failures should not abort execution.
+	KeepGoing bool
+
+	// Progress is used to let the executor know that the marionette
+	// CLI was started with the `-progress` flag present, so a count
+	// of the rules which have been processed should be shown.
+	Progress bool
+
+	// Strict is used to let the executor know that the marionette
+	// CLI was started with the `-strict` flag present, so rules which
+	// use unknown parameters should be rejected.
+	Strict bool
+
 	// Verbose is used to let our plugins know that the marionette
 	// CLI was started with the `-verbose` flag present.
 	Verbose bool
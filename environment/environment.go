@@ -8,15 +8,30 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"regexp"
 	"runtime"
 	"strings"
 )
 
+// arrayRef matches a string which is nothing more than a reference to
+// a single variable, such as "${pkgs}".
+var arrayRef = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+
 // Environment stores our state
 type Environment struct {
 
 	// The variables we're holding.
 	vars map[string]string
+
+	// The array-variables we're holding.
+	//
+	// Each of these also has an entry in vars, containing the
+	// values joined by ",", for use in strings.
+	arrays map[string][]string
+
+	// The names of the variables which should be saved, and
+	// restored, between runs.
+	persist map[string]bool
 }
 
 // New returns a new Environment object.
@@ -26,7 +41,9 @@ type Environment struct {
 // the operating-system upon which we're running, & etc.
 func New() *Environment {
 	// Create a new environment
-	tmp := &Environment{vars: make(map[string]string)}
+	tmp := &Environment{vars: make(map[string]string),
+		arrays:  make(map[string][]string),
+		persist: make(map[string]bool)}
 
 	// Set some default values
 	tmp.vars["ARCH"] = runtime.GOARCH
@@ -52,6 +69,9 @@ func New() *Environment {
 		tmp.vars["HOMEDIR"] = user.HomeDir
 	}
 
+	// Set the facts about the local system.
+	tmp.setFacts()
+
 	// Log our default variables
 	for key, val := range tmp.vars {
 		log.Printf("[DEBUG] Set default variable %s -> %s\n", key, val)
@@ -66,6 +86,55 @@ func New() *Environment {
 // Any previously-existing value will be overwritten.
 func (e *Environment) Set(key string, val string) {
 	e.vars[key] = val
+	delete(e.arrays, key)
+}
+
+// SetArray updates the environment to store the given array of values
+// against the specified key.
+//
+// When the variable is used within a string the values are joined
+// with ",".
+func (e *Environment) SetArray(key string, vals []string) {
+	e.vars[key] = strings.Join(vals, ",")
+	e.arrays[key] = vals
+}
+
+// Unset removes the given key from the environment.
+func (e *Environment) Unset(key string) {
+	delete(e.vars, key)
+	delete(e.arrays, key)
+	delete(e.persist, key)
+}
+
+// Persist marks the given variable as one which should be written
+// to the state-file, if one is used.
+//
+// Only variables assigned by the user are persisted, so that rule
+// outputs, facts, and other "magic" variables are never saved.
+func (e *Environment) Persist(key string) {
+	if e.persist == nil {
+		e.persist = make(map[string]bool)
+	}
+	e.persist[key] = true
+}
+
+// GetArray retrieves the named array from the environment, along
+// with a boolean value to indicate whether the retrieval was
+// successful.
+func (e *Environment) GetArray(key string) ([]string, bool) {
+	val, ok := e.arrays[key]
+	return val, ok
+}
+
+// ExpandArray returns the values of the array-variable referenced by
+// the given input, if it consists of nothing more than a reference to
+// one, such as "${pkgs}".
+func (e *Environment) ExpandArray(input string) ([]string, bool) {
+	m := arrayRef.FindStringSubmatch(input)
+	if m == nil {
+		return nil, false
+	}
+	return e.GetArray(m[1])
 }
 
 // Get retrieves the named value from the environment, along
@@ -85,6 +154,12 @@ func (e *Environment) Variables() map[string]string {
 	return e.vars
 }
 
+// Arrays returns all of the array-variables which have been set, as
+// well as their values.
+func (e *Environment) Arrays() map[string][]string {
+	return e.arrays
+}
+
 // ExpandVariables takes a string which contains embedded
 // variable references, such as ${USERNAME}, and expands the
 // result.
@@ -1,8 +1,11 @@
 package environment
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -91,4 +94,191 @@ func TestSet(t *testing.T) {
 		t.Fatalf("Wrong value retrieved, after update")
 	}
 
+	// Remove the value
+	e.Unset("STEVE")
+	_, ok = e.Get("STEVE")
+	if ok {
+		t.Fatalf("Got value for STEVE, after removing it")
+	}
+
+}
+
+// TestExpandOnce ensures that variables are only expanded once, so the
+// values of variables are not themselves expanded.
+func TestExpandOnce(t *testing.T) {
+
+	e := New()
+	e.Set("name", "steve")
+	e.Set("raw", "hello ${name}")
+
+	out := e.ExpandVariables("${raw}")
+	if out != "hello ${name}" {
+		t.Fatalf("unexpected second expansion: %s", out)
+	}
+
+	// A second pass is opt-in
+	out = e.ExpandVariables(out)
+	if out != "hello steve" {
+		t.Fatalf("wrong expansion: %s", out)
+	}
+}
+
+// TestArray ensures that arrays may be stored and retrieved.
+func TestArray(t *testing.T) {
+
+	e := New()
+
+	e.SetArray("pkgs", []string{"git", "curl"})
+
+	vals, ok := e.GetArray("pkgs")
+	if !ok || len(vals) != 2 {
+		t.Fatalf("failed to get array")
+	}
+
+	// Used within a string the values are joined
+	out := e.ExpandVariables("install ${pkgs}")
+	if out != "install git,curl" {
+		t.Fatalf("wrong expansion: %s", out)
+	}
+
+	// A bare reference expands to the array
+	vals, ok = e.ExpandArray("${pkgs}")
+	if !ok || strings.Join(vals, " ") != "git curl" {
+		t.Fatalf("wrong array expansion: %v", vals)
+	}
+
+	for _, input := range []string{"${pkgs} ", "x${pkgs}", "${pkgs}${pkgs}", "${missing}", "pkgs"} {
+		_, ok = e.ExpandArray(input)
+		if ok {
+			t.Fatalf("unexpected array expansion of %s", input)
+		}
+	}
+
+	// Setting a string replaces the array
+	e.Set("pkgs", "vim")
+	_, ok = e.GetArray("pkgs")
+	if ok {
+		t.Fatalf("array survived being replaced")
+	}
+}
+
+// TestFacts ensures our facts are present, and parsed correctly.
+func TestFacts(t *testing.T) {
+
+	e := New()
+
+	for _, name := range []string{"FACT_CPU_COUNT", "FACT_MEM_MB", "FACT_DISTRO"} {
+		val, ok := e.Get(name)
+		if !ok || val == "" {
+			t.Fatalf("fact %s was not set", name)
+		}
+	}
+
+	// Write some fake files to parse
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mem := filepath.Join(dir, "meminfo")
+	err = ioutil.WriteFile(mem, []byte("MemTotal:        4194304 kB\nMemFree:   1024 kB\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write meminfo: %s", err)
+	}
+	if memoryMB(mem) != 4096 {
+		t.Fatalf("wrong memory size, got %d", memoryMB(mem))
+	}
+
+	rel := filepath.Join(dir, "os-release")
+	err = ioutil.WriteFile(rel, []byte("NAME=\"Debian GNU/Linux\"\nID=\"debian\"\nVERSION_ID=\"11\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write os-release: %s", err)
+	}
+	if distribution(rel) != "debian" {
+		t.Fatalf("wrong distribution, got %s", distribution(rel))
+	}
+
+	// Missing files result in defaults.
+	if memoryMB(filepath.Join(dir, "missing")) != 0 {
+		t.Fatalf("expected zero memory for a missing file")
+	}
+	if distribution(filepath.Join(dir, "missing")) != "unknown" {
+		t.Fatalf("expected unknown distribution for a missing file")
+	}
+}
+
+// TestState ensures variables can be saved and restored.
+func TestState(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	// Loading a missing file is fine.
+	e := New()
+	err = e.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading missing state: %s", err)
+	}
+
+	// Save some state, including a bogus architecture.
+	e.Set("PASSWORD", "secret")
+	e.Persist("PASSWORD")
+	e.Set("ARCH", "bogus")
+	e.Persist("ARCH")
+	e.Set("rule.changed", "true")
+	err = e.Save(path)
+	if err != nil {
+		t.Fatalf("failed to save state: %s", err)
+	}
+
+	// Load into a fresh environment.
+	n := New()
+	err = n.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	val, ok := n.Get("PASSWORD")
+	if !ok || val != "secret" {
+		t.Fatalf("saved variable wasn't restored")
+	}
+	val, _ = n.Get("ARCH")
+	if val != runtime.GOARCH {
+		t.Fatalf("default variable was replaced by saved state: %s", val)
+	}
+
+	// Variables which weren't persisted aren't saved.
+	_, ok = n.Get("rule.changed")
+	if ok {
+		t.Fatalf("variable which wasn't persisted was restored")
+	}
+
+	// Restored variables are saved again, but nothing else is.
+	err = n.Save(path)
+	if err != nil {
+		t.Fatalf("failed to save state: %s", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read state: %s", err)
+	}
+	if !strings.Contains(string(data), "PASSWORD") || strings.Contains(string(data), "HOSTNAME") {
+		t.Fatalf("unexpected state saved: %s", data)
+	}
+
+	// Invalid JSON is an error.
+	err = ioutil.WriteFile(path, []byte("not json"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	err = n.Load(path)
+	if err == nil {
+		t.Fatalf("expected error loading bogus state")
+	}
 }
@@ -0,0 +1,91 @@
+// facts.go - Contains the discovery of system "facts".
+//
+// Facts are variables which describe the local system, such as the
+// number of CPUs, and the amount of installed RAM.  They're made
+// available to recipes as pre-declared variables.
+
+package environment
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// setFacts populates the environment with the facts we can discover
+// about the local system.
+//
+// Failure to discover a fact is not an error, instead a default value
+// will be used.
+func (e *Environment) setFacts() {
+	e.vars["FACT_CPU_COUNT"] = strconv.Itoa(runtime.NumCPU())
+	e.vars["FACT_MEM_MB"] = strconv.FormatInt(memoryMB("/proc/meminfo"), 10)
+	e.vars["FACT_DISTRO"] = distribution("/etc/os-release")
+}
+
+// memoryMB returns the total amount of RAM, in megabytes, as reported
+// by the given meminfo file.
+//
+// If the memory cannot be determined zero is returned.
+func memoryMB(path string) int64 {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	// We're looking for a line like this:
+	//
+	//   MemTotal:       16307668 kB
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb / 1024
+	}
+
+	return 0
+}
+
+// distribution returns the ID of the Linux distribution, as reported
+// by the given os-release file.
+//
+// If the distribution cannot be determined "unknown" is returned.
+func distribution(path string) string {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+
+	// We're looking for a line like this:
+	//
+	//   ID=debian
+	//
+	// Values may optionally be quoted.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "ID=") {
+			continue
+		}
+
+		id := strings.Trim(strings.TrimPrefix(line, "ID="), "\"'")
+		if id != "" {
+			return id
+		}
+	}
+
+	return "unknown"
+}
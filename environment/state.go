@@ -0,0 +1,65 @@
+package environment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// Load reads previously saved variables from the given JSON file, and
+// sets them in the environment.
+//
+// Variables which are already set are left alone, so the default and
+// "magic" variables are never replaced by stale values.  A missing file
+// is not an error, as it will not exist prior to the first run.
+//
+// Restored variables are persisted again, when the state is saved.
+func (e *Environment) Load(path string) error {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	vars := make(map[string]string)
+	err = json.Unmarshal(data, &vars)
+	if err != nil {
+		return err
+	}
+
+	for key, val := range vars {
+		if _, ok := e.vars[key]; ok {
+			continue
+		}
+		log.Printf("[DEBUG] Set saved variable %s -> %s\n", key, val)
+		e.vars[key] = val
+		e.Persist(key)
+	}
+
+	return nil
+}
+
+// Save writes the persisted variables in the environment to the given
+// file, as JSON.
+//
+// The file is only readable by its owner, as it might contain secrets.
+func (e *Environment) Save(path string) error {
+
+	vars := make(map[string]string)
+	for key := range e.persist {
+		if val, ok := e.vars[key]; ok {
+			vars[key] = val
+		}
+	}
+
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,107 @@
+package executor
+
+import "fmt"
+
+// DependencyError is returned when a rule refers to another rule which
+// doesn't exist, or when a rule cannot be executed because a rule it
+// requires has failed.
+type DependencyError struct {
+
+	// Rule is the name of the rule with the dependency.
+	Rule string
+
+	// Dependency is the name of the rule which was referenced.
+	Dependency string
+
+	// Failed is true if the dependency exists, but failed to execute.
+	Failed bool
+}
+
+// Error is part of the error-interface.
+func (d *DependencyError) Error() string {
+	if d.Failed {
+		return fmt.Sprintf("skipping rule %s, as its dependency %s failed", d.Rule, d.Dependency)
+	}
+	return fmt.Sprintf("rule '%s' has reference to '%s' which doesn't exist", d.Rule, d.Dependency)
+}
+
+// ModuleError is returned when a module rejects the parameters of a
+// rule, or fails to execute it.
+type ModuleError struct {
+
+	// Rule is the name of the rule which failed.
+	Rule string
+
+	// Type is the type of the module which was used.
+	Type string
+
+	// Validating is true if the failure happened when the module
+	// was checking the parameters, rather than executing the rule.
+	Validating bool
+
+	// Cause is the error the module returned.
+	Cause error
+}
+
+// Error is part of the error-interface.
+func (m *ModuleError) Error() string {
+	action := "running"
+	if m.Validating {
+		action = "validating"
+	}
+	return fmt.Sprintf("error %s %s-module rule '%s' %s", action, m.Type, m.Rule, m.Cause)
+}
+
+// Unwrap returns the error the module returned.
+func (m *ModuleError) Unwrap() error {
+	return m.Cause
+}
+
+// ConditionError is returned when the `if` or `unless` condition of a
+// rule could not be evaluated.
+type ConditionError struct {
+
+	// Rule is the name of the rule with the condition.
+	Rule string
+
+	// Condition is the type of the condition, "if" or "unless".
+	Condition string
+
+	// Cause is the error which occurred.
+	Cause error
+}
+
+// Error is part of the error-interface.
+func (c *ConditionError) Error() string {
+	return fmt.Sprintf("error testing '%s' condition for rule '%s' %s", c.Condition, c.Rule, c.Cause)
+}
+
+// Unwrap returns the error which occurred.
+func (c *ConditionError) Unwrap() error {
+	return c.Cause
+}
+
+// ParseError is returned when a recipe, or a file it includes, could
+// not be parsed.
+type ParseError struct {
+
+	// File is the name of the file which failed to parse, which may
+	// be empty if the recipe wasn't read from a file.
+	File string
+
+	// Cause is the error the parser returned.
+	Cause error
+}
+
+// Error is part of the error-interface.
+func (p *ParseError) Error() string {
+	if p.File == "" {
+		return p.Cause.Error()
+	}
+	return fmt.Sprintf("failed to parse %s: %s", p.File, p.Cause)
+}
+
+// Unwrap returns the error the parser returned.
+func (p *ParseError) Unwrap() error {
+	return p.Cause
+}
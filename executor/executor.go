@@ -9,9 +9,13 @@ package executor
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/skx/marionette/ast"
@@ -21,6 +25,16 @@ import (
 	"github.com/skx/marionette/parser"
 )
 
+// Change records a rule which resulted in a change being made.
+type Change struct {
+
+	// Name is the name of the rule.
+	Name string
+
+	// Type is the type of the module the rule used.
+	Type string
+}
+
 // Executor holds our internal state.
 type Executor struct {
 
@@ -38,14 +52,35 @@ type Executor struct {
 	// Keep track of which rules we've executed.
 	executed map[string]bool
 
+	// Keep track of which rules failed, when we're continuing
+	// past failures.
+	failed map[string]bool
+
 	// included keeps track of which files we've already included.
 	//
 	// We use this to avoid issues with recursive file inclusions.
 	included map[string]bool
 
+	// exiting is true when we're running the at_exit rules.
+	exiting bool
+
 	// cfg holds our configuration options.
 	cfg *config.Config
 
+	// observer is informed as rules are executed, if set.
+	observer Observer
+
+	// total is the number of rules which we expect to process,
+	// ignoring those which are triggered or run at exit.
+	total int
+
+	// processed is the number of those rules we've processed.
+	processed int
+
+	// changes holds the rules which resulted in a change, in the
+	// order they were executed.
+	changes []Change
+
 	// env holds the environment.
 	env *environment.Environment
 }
@@ -60,20 +95,113 @@ func New(program []ast.Node) *Executor {
 	e := &Executor{
 		cfg:      &config.Config{},
 		env:      environment.New(),
-		Program:  program,
+		Program:  expandLoops(program),
 		included: make(map[string]bool),
 		executed: make(map[string]bool),
+		failed:   make(map[string]bool),
 		index:    make(map[string]int),
 	}
 
 	return e
 }
 
+// expandLoops replaces each foreach-loop within the given program with
+// the statements it contains, repeated once for each value.
+//
+// Each iteration is preceded by an assignment of the loop variable, and
+// the rules within it are renamed to keep their names unique.  References
+// between rules in the same iteration are updated to match.
+func expandLoops(program []ast.Node) []ast.Node {
+
+	var out []ast.Node
+
+	for _, node := range program {
+
+		loop, ok := node.(*ast.Foreach)
+		if !ok {
+			out = append(out, node)
+			continue
+		}
+
+		// Expand any nested loops first.
+		body := expandLoops(loop.Body)
+
+		for i, val := range loop.Values.Values {
+
+			// Set the loop variable.
+			out = append(out, &ast.Assign{Key: loop.Variable, Value: val})
+
+			// Rename the rules for this iteration.
+			names := make(map[string]string)
+			for _, n := range body {
+				if rule, ok := n.(*ast.Rule); ok {
+					names[rule.Name] = fmt.Sprintf("%s-%d", rule.Name, i+1)
+				}
+			}
+
+			for _, n := range body {
+				rule, ok := n.(*ast.Rule)
+				if !ok {
+					out = append(out, n)
+					continue
+				}
+				out = append(out, cloneRule(rule, names))
+			}
+		}
+	}
+
+	return out
+}
+
+// cloneRule returns a copy of the given rule, renamed and with its
+// dependencies updated according to the given map of names.
+func cloneRule(rule *ast.Rule, names map[string]string) *ast.Rule {
+
+	clone := *rule
+	clone.Name = names[rule.Name]
+	clone.Params = make(map[string]interface{})
+
+	for k, v := range rule.Params {
+
+		if k == "require" || k == "notify" {
+			v = renameDeps(v, names)
+		}
+		clone.Params[k] = v
+	}
+
+	return &clone
+}
+
+// renameDeps updates the rule-names in the given require/notify value.
+func renameDeps(value interface{}, names map[string]string) interface{} {
+
+	switch v := value.(type) {
+	case ast.String:
+		if name, ok := names[v.Value]; ok {
+			return ast.String{Value: name}
+		}
+	case ast.Array:
+		tmp := ast.Array{}
+		for _, x := range v.Values {
+			tmp.Values = append(tmp.Values, renameDeps(x, names).(ast.Object))
+		}
+		return tmp
+	}
+
+	return value
+}
+
 // SetConfig updates the executor with the specified configuration object.
 func (e *Executor) SetConfig(cfg *config.Config) {
 	e.cfg = cfg
 }
 
+// Changes returns the rules which resulted in a change being made,
+// including those within included files.
+func (e *Executor) Changes() []Change {
+	return e.changes
+}
+
 // MarkSeen marks the given file as having already been seen.
 func (e *Executor) MarkSeen(path string) {
 	e.included[path] = true
@@ -96,6 +224,26 @@ func (e *Executor) SetMagicIncludeVars(path string) error {
 	return nil
 }
 
+// SetVariable sets the given variable, before the rules are executed.
+//
+// Any `let` statement within the rules will replace the value.
+func (e *Executor) SetVariable(key string, val string) {
+	e.env.Set(key, val)
+}
+
+// LoadState loads variables which were saved by a previous run.
+//
+// Variables which are already defined are not replaced.
+func (e *Executor) LoadState(path string) error {
+	return e.env.Load(path)
+}
+
+// SaveState saves the variables assigned by `let` statements, so that
+// they may be loaded by a future run.
+func (e *Executor) SaveState(path string) error {
+	return e.env.Save(path)
+}
+
 // Get the rules a rule depends upon, via the given key.
 //
 // This is used to find any `require` or `notify` rules.
@@ -168,6 +316,17 @@ func (e *Executor) Check() error {
 	// a rule that we can't find.
 	//
 
+	//
+	// Ensure that all function-calls are valid, so we can report
+	// mistakes before anything is executed.
+	//
+	for _, r := range e.Program {
+		err := e.checkCalls(r)
+		if err != nil {
+			return err
+		}
+	}
+
 	//
 	// Walk over all the nodes we've got
 	//
@@ -193,6 +352,13 @@ func (e *Executor) Check() error {
 		// Save the index away
 		//
 		e.index[rule.Name] = i
+
+		//
+		// Count the rules we'll process, for our progress.
+		//
+		if !rule.Triggered && !rule.AtExit {
+			e.total++
+		}
 	}
 
 	//
@@ -206,6 +372,17 @@ func (e *Executor) Check() error {
 			continue
 		}
 
+		//
+		// In strict mode reject any parameters the module
+		// doesn't recognize.
+		//
+		if e.cfg.Strict {
+			err := e.checkParams(rule)
+			if err != nil {
+				return err
+			}
+		}
+
 		//
 		// Get the dependencies of that rule, and the things
 		// it will notify in the event it is triggered.
@@ -242,16 +419,233 @@ func (e *Executor) Check() error {
 			// Does the requirement exist?
 			_, found := e.index[dep]
 			if !found {
-				return fmt.Errorf("rule '%s' has reference to '%s' which doesn't exist", rule.Name, dep)
+				return &DependencyError{Rule: rule.Name, Dependency: dep}
+			}
+		}
+
+		// Triggered rules only run when notified, so requiring
+		// one is almost certainly a mistake.
+		for _, dep := range deps {
+			dr := e.Program[e.index[dep]].(*ast.Rule)
+			if dr.Triggered {
+				log.Printf("[WARN] rule '%s' requires '%s', which is triggered and will only run when notified", rule.Name, dep)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkCalls ensures that every function-call within the given node,
+// including any conditions, has a valid number of arguments.
+func (e *Executor) checkCalls(node ast.Node) error {
+
+	switch n := node.(type) {
+	case *ast.Assign:
+		err := checkObjects(n.Value, n.Function)
+		if err != nil {
+			return fmt.Errorf("error in assignment to '%s': %s", n.Key, err)
+		}
+	case *ast.Include:
+		err := checkObjects(n.Source, n.Function)
+		if err != nil {
+			return fmt.Errorf("error in include of '%s': %s", n.Source, err)
+		}
+	case *ast.IncludeDir:
+		err := checkObjects(n.Source, n.Function)
+		if err != nil {
+			return fmt.Errorf("error in include_dir of '%s': %s", n.Source, err)
+		}
+	case *ast.Rule:
+		values := []interface{}{n.Function}
+		for _, v := range n.Params {
+			values = append(values, v)
+		}
+		err := checkObjects(values...)
+		if err != nil {
+			return fmt.Errorf("error in rule '%s': %s", n.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkObjects validates any function-calls within the given values,
+// which may be single objects or arrays of them.
+//
+// Conditions which are not set are empty function-calls, and these
+// are ignored.
+func checkObjects(values ...interface{}) error {
+
+	for _, value := range values {
+
+		switch v := value.(type) {
+		case ast.Funcall:
+			if v.Name == "" {
+				continue
+			}
+			err := v.CheckArgs()
+			if err != nil {
+				return err
+			}
+		case ast.Array:
+			err := checkObjects(objects(v.Values)...)
+			if err != nil {
+				return err
+			}
+		case []ast.Object:
+			err := checkObjects(objects(v)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// objects converts the given array of objects into an array of
+// interfaces, which is suitable for passing to checkObjects.
+func objects(values []ast.Object) []interface{} {
+	var out []interface{}
+	for _, v := range values {
+		out = append(out, v)
+	}
+	return out
+}
+
+// magicKeys are the parameters which may be supplied to all rules,
+// regardless of their type.
+var magicKeys = map[string]bool{
+	"name":         true,
+	"description":  true,
+	"require":      true,
+	"notify":       true,
+	"if":           true,
+	"unless":       true,
+	"with":         true,
+	"changed_when": true,
+	"register":     true,
+	"os":           true,
+}
+
+// checkParams ensures that the given rule only uses parameters which its
+// module recognizes.
+//
+// Modules which don't implement the ModuleParams interface accept all
+// parameters.
+func (e *Executor) checkParams(rule *ast.Rule) error {
+
+	helper := modules.Lookup(rule.Type, e.cfg, e.env)
+	known, ok := helper.(modules.ModuleParams)
+	if !ok {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range known.KnownParams() {
+		allowed[name] = true
+	}
+
+	// Sort the parameters, so that we report the same error each
+	// time if there are several unknown ones.
+	var keys []string
+	for key := range rule.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !magicKeys[key] && !allowed[key] {
+			return &ModuleError{Rule: rule.Name, Type: rule.Type, Validating: true, Cause: fmt.Errorf("unknown parameter '%s'", key)}
+		}
+	}
+
+	return nil
+}
+
+// Graph writes the rules, and the dependencies between them, to the
+// given writer as a graphviz digraph.
+//
+// Triggered rules are drawn with dashed outlines.  This should be called
+// after Check, which ensures the dependencies are valid.
+func (e *Executor) Graph(w io.Writer) error {
+
+	fmt.Fprintf(w, "digraph rules {\n")
+
+	for _, r := range e.Program {
+
+		// Skip nodes which are not ast.Rules
+		rule, ok := r.(*ast.Rule)
+		if !ok {
+			continue
+		}
+
+		style := ""
+		if rule.Triggered {
+			style = ", style=dashed"
+		}
+		label := rule.Type + ": " + rule.Name
+		if rule.Description != "" {
+			label += "\n" + rule.Description
+		}
+		fmt.Fprintf(w, "  %q [label=%q%s];\n", rule.Name, label, style)
+
+		for _, key := range []string{"require", "notify"} {
+
+			deps, err := e.deps(rule, key)
+			if err != nil {
+				return err
+			}
+
+			for _, dep := range deps {
+				fmt.Fprintf(w, "  %q -> %q [label=%q];\n", rule.Name, dep, key)
 			}
 		}
 	}
 
+	fmt.Fprintf(w, "}\n")
 	return nil
 }
 
+// List writes the type and name of each rule to the given writer, one
+// per line, along with the description of the rule if it has one.
+func (e *Executor) List(w io.Writer) {
+
+	for _, r := range e.Program {
+
+		// Skip nodes which are not ast.Rules
+		rule, ok := r.(*ast.Rule)
+		if !ok {
+			continue
+		}
+
+		if rule.Description == "" {
+			fmt.Fprintf(w, "%s %s\n", rule.Type, rule.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s %s - %s\n", rule.Type, rule.Name, rule.Description)
+	}
+}
+
 // Execute runs the rules in turn, handling any dependency ordering.
-func (e *Executor) Execute() error {
+func (e *Executor) Execute() (err error) {
+
+	// Run any at_exit rules once we're done, even if we fail.
+	defer func() {
+		exitErr := e.executeAtExit()
+		if exitErr != nil {
+			if err == nil {
+				err = exitErr
+			} else {
+				err = fmt.Errorf("%s\n%s", err, exitErr)
+			}
+		}
+	}()
+
+	// The names of any rules which failed, if we're continuing past
+	// failures.
+	var failures []string
 
 	// For each node in our program
 	for _, r := range e.Program {
@@ -276,7 +670,31 @@ func (e *Executor) Execute() error {
 			// include-file handling
 			err := e.executeInclude(r)
 			if err != nil {
-				return err
+				if !e.cfg.KeepGoing {
+					return err
+				}
+
+				// Record the failure, and continue.
+				name := e.includeName(r.Source)
+				log.Printf("[ERROR] %s failed: %s", name, err)
+				failures = append(failures, name)
+			}
+
+		case *ast.IncludeDir:
+
+			log.Printf("[DEBUG] Processing directory inclusion: %v\n", r)
+
+			// include-directory handling
+			err := e.executeIncludeDir(r)
+			if err != nil {
+				if !e.cfg.KeepGoing {
+					return err
+				}
+
+				// Record the failure, and continue.
+				name := e.includeName(r.Source)
+				log.Printf("[ERROR] %s failed: %s", name, err)
+				failures = append(failures, name)
 			}
 
 		case *ast.Rule:
@@ -286,13 +704,63 @@ func (e *Executor) Execute() error {
 			// rule execution
 			err := e.executeSingleRule(r, false)
 			if err != nil {
-				return err
+				if !e.cfg.KeepGoing {
+					return err
+				}
+
+				// Record the failure, and continue.
+				log.Printf("[ERROR] rule %s failed: %s", r.Name, err)
+				failures = append(failures, r.Name)
 			}
 
 		default:
 			return fmt.Errorf("unknown node type! %t", r)
 		}
 	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d rule(s) failed: %s", len(failures), strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// includeName returns a description of an inclusion, for use when
+// reporting its failure.
+func (e *Executor) includeName(source ast.Object) string {
+	path, err := source.Evaluate(e.env)
+	if err != nil {
+		return "include"
+	}
+	return "include " + path
+}
+
+// executeAtExit runs each of the rules with the at_exit modifier, in the
+// order they were declared.
+//
+// All the rules are executed, even if some fail, and their errors are
+// returned together.
+func (e *Executor) executeAtExit() error {
+
+	e.exiting = true
+
+	var errs []string
+
+	for _, r := range e.Program {
+
+		rule, ok := r.(*ast.Rule)
+		if !ok || !rule.AtExit {
+			continue
+		}
+
+		err := e.executeSingleRule(rule, false)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("at_exit rule %s failed: %s", rule.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
 	return nil
 }
 
@@ -319,6 +787,21 @@ func (e *Executor) executeAssign(assign *ast.Assign) error {
 	// The key
 	key := assign.Key
 
+	// Arrays are stored as such, so they may be used as array
+	// parameters later.
+	if array, ok := assign.Value.(ast.Array); ok {
+
+		vals, err := e.evaluateArray(array)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[DEBUG] Set '%s' -> [%s]", key, strings.Join(vals, ","))
+		e.env.SetArray(key, vals)
+		e.env.Persist(key)
+		return nil
+	}
+
 	// Execute the literal object (be it a number, string, backtick or bool)
 	val, err := assign.Value.Evaluate(e.env)
 	if err != nil {
@@ -328,8 +811,9 @@ func (e *Executor) executeAssign(assign *ast.Assign) error {
 	// Show what we're going to do.
 	log.Printf("[DEBUG] Set '%s' -> '%s'", key, val)
 
-	// Set the value
+	// Set the value, and save it between runs.
 	e.env.Set(key, val)
+	e.env.Persist(key)
 	return nil
 }
 
@@ -358,61 +842,157 @@ func (e *Executor) executeInclude(inc *ast.Include) error {
 	// We might have:
 	//
 	//   include "path/to/file"
+	//   include "path/to/*.rules"
 	//   include true
 	//   include [ "one.txt", "two.txt" ]
 	//
 	// Because the array value will handle multiple values we'll
-	// expand them as we go.
-	includes := []string{}
-
-	//
-	// Is this an array?
-	//
-	array, ok := inc.Source.(ast.Array)
-	if ok {
-
-		// If so evaluate each node and save it
-		// in our list of things to include.
-		for _, p := range array.Values {
+	// expand them as we go, along with any glob-patterns.
+	sources, err := e.evaluateSources(inc.Source)
+	if err != nil {
+		return err
+	}
 
-			val, err2 := p.Evaluate(e.env)
-			if err2 != nil {
-				return err2
-			}
+	// Expand any glob-patterns we've been given.
+	includes := []string{}
+	for _, source := range sources {
 
-			// save into our array of strings
-			includes = append(includes, val)
+		if !strings.ContainsAny(source, "*?[") {
+			includes = append(includes, source)
+			continue
 		}
 
-	} else {
-
-		// OK this isn't an array, so we can just
-		// handle it as a single-thing.
-		val, err2 := inc.Source.Evaluate(e.env)
-		if err2 != nil {
-			return err2
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %s: %s", source, err)
 		}
+		if len(matches) == 0 {
+			log.Printf("[INFO] Include pattern %s matched no files", source)
+		}
+		sort.Strings(matches)
 
-		includes = append(includes, val)
+		includes = append(includes, matches...)
 	}
 
-	// For each thing to include ..
-	for _, path := range includes {
+	return e.includeFiles(includes)
+}
 
-		// If we've already included this path, skip it
-		seen, ok := e.included[path]
-		if ok && seen {
-			log.Printf("[INFO] Skipping inclusion of %s - already seen", path)
-			continue
-		}
+// executeIncludeDir will handle a directory inclusion node, including
+// each rule-file within the directory in sorted order.
+func (e *Executor) executeIncludeDir(inc *ast.IncludeDir) error {
 
-		// Mark it as included now.
-		e.MarkSeen(path)
+	// OK is this conditionally assigned?
+	if inc.ConditionType != "" {
+
+		// Should we execute the inclusion?
+		ret, err := e.shouldExecute(inc.ConditionType, inc.Function)
+
+		// Error?  Then return that
+		if err != nil {
+			return err
+		}
+
+		// If we didn't get a "true" then we should skip this action.
+		if !ret {
+			return nil
+		}
+	}
+
+	// Get the directory, or directories, to include.
+	dirs, err := e.evaluateSources(inc.Source)
+	if err != nil {
+		return err
+	}
+
+	// Find the rule-files in each directory.
+	includes := []string{}
+	for _, dir := range dirs {
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("failed to include directory %s: %s", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("failed to include directory %s: not a directory", dir)
+		}
+
+		var files []string
+		for _, pattern := range []string{"*.hcl", "*.recipe", "*.rules"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return err
+			}
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+
+		log.Printf("[DEBUG] Directory %s contains [%s]\n", dir, strings.Join(files, ","))
+
+		includes = append(includes, files...)
+	}
+
+	return e.includeFiles(includes)
+}
+
+// evaluateSources evaluates the source of an include statement, which
+// might be a single object, or an array of them.
+func (e *Executor) evaluateSources(source ast.Object) ([]string, error) {
+
+	sources := []string{}
+
+	//
+	// Is this an array?
+	//
+	array, ok := source.(ast.Array)
+	if ok {
+
+		// If so evaluate each node and save it
+		// in our list of things to include.
+		for _, p := range array.Values {
+
+			val, err := p.Evaluate(e.env)
+			if err != nil {
+				return nil, err
+			}
+
+			// save into our array of strings
+			sources = append(sources, val)
+		}
+
+		return sources, nil
+	}
+
+	// OK this isn't an array, so we can just
+	// handle it as a single-thing.
+	val, err := source.Evaluate(e.env)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sources, val), nil
+}
+
+// includeFiles includes each of the given files, skipping any which
+// have already been included.
+func (e *Executor) includeFiles(includes []string) error {
+
+	// For each thing to include ..
+	for _, path := range includes {
+
+		// If we've already included this path, skip it
+		seen, ok := e.included[path]
+		if ok && seen {
+			log.Printf("[INFO] Skipping inclusion of %s - already seen", path)
+			continue
+		}
+
+		// Mark it as included now.
+		e.MarkSeen(path)
 
 		// And read/run it.
 		err := e.executeIncludeReal(path)
 		if err != nil {
-			return fmt.Errorf("failed to execute included file %s: %s", path, err)
+			return fmt.Errorf("failed to execute included file %s: %w", path, err)
 		}
 	}
 
@@ -426,7 +1006,7 @@ func (e *Executor) executeIncludeReal(source string) error {
 	// Read the source we're to include
 	data, err := ioutil.ReadFile(source)
 	if err != nil {
-		return fmt.Errorf("failed to read include-source %s: %s", source, err)
+		return fmt.Errorf("failed to read include-source %s: %w", source, err)
 	}
 
 	// Create a new parser with our file content.
@@ -435,19 +1015,23 @@ func (e *Executor) executeIncludeReal(source string) error {
 	// Parse the rules
 	out, err := p.Parse()
 	if err != nil {
-		return err
+		return &ParseError{File: source, Cause: err}
 	}
 
 	// Create the new executor
 	ex := New(out.Recipe)
 
-	// Set the configuration options.
+	// Set the configuration options, and the observer.
 	ex.SetConfig(e.cfg)
+	ex.SetObserver(e.observer)
 
 	// Propagate all the variables which we have in-scope.
 	for k, v := range e.env.Variables() {
 		ex.env.Set(k, v)
 	}
+	for k, v := range e.env.Arrays() {
+		ex.env.SetArray(k, v)
+	}
 
 	// Set "magic" variables for the current include file.
 	err = ex.SetMagicIncludeVars(source)
@@ -468,6 +1052,10 @@ func (e *Executor) executeIncludeReal(source string) error {
 
 	// Now execute!
 	err = ex.Execute()
+
+	// Record the changes the child made, even if it failed.
+	e.changes = append(e.changes, ex.changes...)
+
 	if err != nil {
 		return err
 	}
@@ -522,13 +1110,30 @@ func (e *Executor) shouldExecute(cType string, cRule ast.Funcall) (bool, error)
 }
 
 // executeSingleRule creates the appropriate module, and runs the single rule.
+//
+// If the rule fails it is recorded, so that any rules which depend upon
+// it can be skipped when we're continuing past failures.
 func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 
+	err := e.executeSingleRuleReal(rule, force)
+	if err != nil {
+		e.failed[rule.Name] = true
+	}
+	return err
+}
+
+// executeSingleRuleReal is the function which actually runs the rule.
+func (e *Executor) executeSingleRuleReal(rule *ast.Rule, force bool) error {
+
 	// Show what we're doing
 	log.Printf("[INFO] Running %s-module rule: %s", rule.Type, rule.Name)
 
 	// Don't run rules that are only present to
 	// be notified by a trigger.
+	//
+	// This applies even when the rule is listed as a
+	// requirement of another; triggered rules only ever
+	// run via notify.
 	if rule.Triggered {
 		if force {
 			log.Printf("[DEBUG] Forcing execution of rule due to notify action")
@@ -538,12 +1143,25 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 		}
 	}
 
+	// Don't run rules which should be run at exit, until then.
+	if rule.AtExit && !e.exiting {
+		log.Printf("[DEBUG] Skipping rule because it has the at_exit-modifier")
+		return nil
+	}
+
 	// Have we executed this rule already?
+	//
+	// This also ensures that a rule which is notified by several
+	// other rules will run at most once.
 	if e.executed[rule.Name] {
 		log.Printf("[DEBUG] Skipping rule because it has already executed")
 		return nil
 	}
 
+	// Mark the rule as executed before we process its dependencies,
+	// or run it, so that any notification which arrives while we're
+	// doing so - for example from one of its own dependencies - is
+	// ignored rather than running the rule a second time.
 	e.executed[rule.Name] = true
 
 	// Get the rule dependencies.
@@ -563,6 +1181,37 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 			return err
 		}
 
+		// The dependency might have failed previously, in which
+		// case we can't run.
+		if e.failed[dr.Name] {
+			err = &DependencyError{Rule: rule.Name, Dependency: dr.Name, Failed: true}
+			if e.observer != nil {
+				e.observer.RuleSkipped(rule.Name, err.Error())
+			}
+			return err
+		}
+
+	}
+
+	// Show our progress, if we should.
+	if !rule.Triggered && !rule.AtExit {
+		e.processed++
+		if e.cfg.Progress {
+			log.Printf("[USER] [%d/%d] running rule %s", e.processed, e.total, rule.Name)
+		}
+	}
+
+	// Is this rule restricted to particular operating systems?
+	match, oErr := e.matchesOS(rule)
+	if oErr != nil {
+		return oErr
+	}
+	if !match {
+		log.Printf("[DEBUG] Skipping rule because it doesn't apply to this operating system: %s", runtime.GOOS)
+		if e.observer != nil {
+			e.observer.RuleSkipped(rule.Name, "operating system did not match")
+		}
+		return nil
 	}
 
 	// OK is this conditionally executed?
@@ -573,11 +1222,14 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 
 		// Error?  Then return that
 		if err != nil {
-			return err
+			return &ConditionError{Rule: rule.Name, Condition: rule.ConditionType, Cause: err}
 		}
 
 		// If we didn't get a "true" then we should skip this action.
 		if !ret {
+			if e.observer != nil {
+				e.observer.RuleSkipped(rule.Name, "condition was not met")
+			}
 			return nil
 		}
 	}
@@ -595,8 +1247,17 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 		return fmt.Errorf("unknown module type %s, from rule %v", rule.Type, rule)
 	}
 
+	if e.observer != nil {
+		e.observer.RuleStart(rule.Name, rule.Type)
+	}
+
 	// Run the module instance
 	changed, err = e.runInternalModule(helper, rule)
+
+	if e.observer != nil {
+		e.observer.RuleDone(rule.Name, changed, err)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -605,6 +1266,8 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 
 		log.Printf("[INFO] Rule resulted in a change being made.")
 
+		e.changes = append(e.changes, Change{Name: rule.Name, Type: rule.Type})
+
 		// Now call any rules that we should notify.
 		notify, nErr := e.deps(rule, "notify")
 		if nErr != nil {
@@ -634,77 +1297,151 @@ func (e *Executor) executeSingleRule(rule *ast.Rule, force bool) error {
 	return nil
 }
 
-// runInternalModule executes the given rule with the loaded internal module.
-func (e *Executor) runInternalModule(helper modules.ModuleAPI, rule *ast.Rule) (bool, error) {
+// evaluateArray expands each of the values in the given array.
+//
+// Any value which is a reference to an array-variable is replaced by
+// the contents of that array.
+func (e *Executor) evaluateArray(array ast.Array) ([]string, error) {
 
-	var err error
+	var tmp []string
 
-	// Expand all params into strings/arrays of strings
-	// into a new map.  We leave the rule-params alone.
-	params := make(map[string]interface{})
+	for _, p := range array.Values {
 
-	// So for each argument
-	for k, v := range rule.Params {
+		if str, ok := p.(ast.String); ok {
+			if vals, ok := e.env.ExpandArray(str.Value); ok {
+				tmp = append(tmp, vals...)
+				continue
+			}
+		}
 
-		// Is this parameter value an array?
-		//
-		// If so expand each value it contains.
-		array, ok := v.(ast.Array)
-		if ok {
+		val, err := p.Evaluate(e.env)
+		if err != nil {
+			return nil, err
+		}
 
-			// temporary values
-			var tmp []string
+		tmp = append(tmp, val)
+	}
 
-			// for each node
-			for _, p := range array.Values {
+	return tmp, nil
+}
 
-				val, err2 := p.Evaluate(e.env)
-				if err2 != nil {
-					return false, err2
-				}
+// setRuleVariables sets the variables given in the `with` parameter of
+// the rule, each of which is in the form "key=value".
+//
+// The function returned restores the previous values of the variables,
+// and must be called once the rule has been executed.
+func (e *Executor) setRuleVariables(rule *ast.Rule) (func(), error) {
 
-				// save into our array of strings
-				tmp = append(tmp, val)
-			}
+	nop := func() {}
 
-			params[k] = tmp
+	with, ok := rule.Params["with"]
+	if !ok {
+		return nop, nil
+	}
 
-			continue
+	// The variables may be a single value, or an array.
+	var objs []ast.Object
+	switch v := with.(type) {
+	case ast.Array:
+		objs = v.Values
+	case ast.Object:
+		objs = []ast.Object{v}
+	default:
+		return nop, fmt.Errorf("unknown object for 'with' - %v %T", with, with)
+	}
+
+	// Expand the values in the current environment, before
+	// we change anything.
+	vars := make(map[string]string)
+	var keys []string
+	for _, obj := range objs {
+
+		str, err := obj.Evaluate(e.env)
+		if err != nil {
+			return nop, err
 		}
 
-		// parameter contains a single node?
-		p, ok := v.(ast.Object)
-		if ok {
+		kv := strings.SplitN(str, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nop, fmt.Errorf("'with' values for rule '%s' must be of the form key=value, got '%s'", rule.Name, str)
+		}
 
-			// Is it a single node, which we can convert?
-			val, err2 := p.Evaluate(e.env)
-			if err2 != nil {
-				return false, err2
-			}
-			params[k] = val
+		if _, seen := vars[kv[0]]; !seen {
+			keys = append(keys, kv[0])
+		}
+		vars[kv[0]] = kv[1]
+	}
 
-			continue
+	// Save the previous values, and set the new ones.
+	type saved struct {
+		val string
+		ok  bool
+	}
+	old := make(map[string]saved)
+
+	for _, key := range keys {
+		val, ok := e.env.Get(key)
+		old[key] = saved{val: val, ok: ok}
+
+		log.Printf("[DEBUG] Setting %s => %s for rule %s\n", key, vars[key], rule.Name)
+		e.env.Set(key, vars[key])
+	}
+
+	restore := func() {
+		for _, key := range keys {
+			if old[key].ok {
+				e.env.Set(key, old[key].val)
+			} else {
+				e.env.Unset(key)
+			}
 		}
+	}
 
-		// We got a parameter which is unknown
-		return false, fmt.Errorf("runInternalModule unknown object at deps - %V %T", v, v)
+	return restore, nil
+}
+
+// runInternalModule executes the given rule with the loaded internal module.
+func (e *Executor) runInternalModule(helper modules.ModuleAPI, rule *ast.Rule) (bool, error) {
+
+	var err error
 
+	// Set any variables which are scoped to this rule, before
+	// we expand the rest of the parameters.
+	restore, err := e.setRuleVariables(rule)
+	if err != nil {
+		return false, err
 	}
+	defer restore()
 
-	// Check the arguments, using the module-specific Check method.
-	err = helper.Check(params)
+	// The outputs may also be registered under a name of the user's
+	// choosing.
+	register, err := e.registerName(rule)
 	if err != nil {
-		return false, fmt.Errorf("error validating %s-module rule '%s' %s",
-			rule.Type, rule.Name, err.Error())
+		return false, err
 	}
 
-	// Execute the module.
-	changed, err := helper.Execute(params)
+	// Rules using some modules may be applied to each of their
+	// targets in turn.
+	items, err := e.ruleItems(rule)
 	if err != nil {
-		return false, fmt.Errorf("error running %s-module rule '%s' %s",
-			rule.Type, rule.Name, err.Error())
+		return false, err
+	}
+
+	var changed bool
+	if items == nil {
+		changed, err = e.executeModule(helper, rule, nil)
+	} else {
+		changed, err = e.executeItems(helper, rule, items)
+	}
+	if err != nil {
+		return false, err
 	}
 
+	// The outputs of the module are also available via "self." while
+	// we evaluate any post-execution expressions, so that a rule may
+	// refer to them without knowing its own name.
+	self := map[string]string{}
+
 	// Now that execution is complete it might be that the module
 	// wishes to store variables in the environment.
 	//
@@ -731,6 +1468,35 @@ func (e *Executor) runInternalModule(helper modules.ModuleAPI, rule *ast.Rule) (
 			log.Printf("[DEBUG] SetOutputVariable (%s) => %s\n",
 				name, val)
 			e.env.Set(name, val)
+
+			if register != "" {
+				e.env.Set(register+"."+key, val)
+			}
+
+			self["self."+key] = val
+		}
+	}
+
+	// If the rule has a changed_when expression then that decides
+	// whether a change was made, rather than the module.
+	//
+	// This is evaluated now so that it can refer to the outputs
+	// of the rule.
+	if expr, ok := rule.Params["changed_when"]; ok {
+
+		self["self.changed"] = fmt.Sprintf("%t", changed)
+		for key, val := range self {
+			e.env.Set(key, val)
+		}
+
+		changed, err = e.changedWhen(rule, expr)
+
+		for key := range self {
+			e.env.Unset(key)
+		}
+
+		if err != nil {
+			return false, err
 		}
 	}
 
@@ -745,6 +1511,268 @@ func (e *Executor) runInternalModule(helper modules.ModuleAPI, rule *ast.Rule) (
 	}
 	e.env.Set(key, val)
 
+	if register != "" {
+		e.env.Set(register+".changed", val)
+	}
+
 	// Finally return the value to the caller.
 	return changed, nil
 }
+
+// matchesOS returns true if the given rule should be executed upon the
+// current operating system.
+//
+// Rules without an "os" parameter apply to all operating systems.
+func (e *Executor) matchesOS(rule *ast.Rule) (bool, error) {
+
+	val, ok := rule.Params["os"]
+	if !ok {
+		return true, nil
+	}
+
+	// The value may be a single name, or an array of them.
+	var names []string
+	switch v := val.(type) {
+	case ast.Array:
+		vals, err := e.evaluateArray(v)
+		if err != nil {
+			return false, err
+		}
+		names = vals
+	case ast.Object:
+		if str, ok := v.(ast.String); ok {
+			if vals, ok := e.env.ExpandArray(str.Value); ok {
+				names = vals
+				break
+			}
+		}
+		name, err := v.Evaluate(e.env)
+		if err != nil {
+			return false, err
+		}
+		names = []string{name}
+	default:
+		return false, fmt.Errorf("unknown object for 'os' - %v %T", val, val)
+	}
+
+	for _, name := range names {
+		if name == runtime.GOOS {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// registerName returns the name the outputs of the given rule should be
+// registered under, if any.
+func (e *Executor) registerName(rule *ast.Rule) (string, error) {
+
+	reg, ok := rule.Params["register"]
+	if !ok {
+		return "", nil
+	}
+
+	obj, ok := reg.(ast.Object)
+	if _, array := reg.(ast.Array); array || !ok {
+		return "", fmt.Errorf("register for rule '%s' must be a single value, got %v", rule.Name, reg)
+	}
+
+	name, err := obj.Evaluate(e.env)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("register for rule '%s' must not be empty", rule.Name)
+	}
+
+	return name, nil
+}
+
+// itemModules contains the types of modules which may be applied to each
+// of the targets of a rule in turn, with ${item} set to the target.
+var itemModules = map[string]bool{
+	"directory": true,
+	"file":      true,
+}
+
+// ruleItems returns the targets of the given rule, if it uses a module
+// which may be applied to each of them in turn, and the targets are an
+// array.
+//
+// If the rule should be executed normally then nil is returned.
+func (e *Executor) ruleItems(rule *ast.Rule) ([]string, error) {
+
+	if !itemModules[rule.Type] {
+		return nil, nil
+	}
+
+	var items []string
+	switch v := rule.Params["target"].(type) {
+	case ast.Array:
+		vals, err := e.evaluateArray(v)
+		if err != nil {
+			return nil, err
+		}
+		items = vals
+	case ast.String:
+		vals, ok := e.env.ExpandArray(v.Value)
+		if !ok {
+			return nil, nil
+		}
+		items = vals
+	}
+
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items, nil
+}
+
+// executeItems runs the module once for each of the given items, with
+// the variable ${item} set to the current item, which is also used as
+// the target.
+//
+// The result is true if any of the executions made a change.
+func (e *Executor) executeItems(helper modules.ModuleAPI, rule *ast.Rule, items []string) (bool, error) {
+
+	// Save any previous value, so that we can restore it.
+	old, set := e.env.Get("item")
+	defer func() {
+		if set {
+			e.env.Set("item", old)
+		} else {
+			e.env.Unset("item")
+		}
+	}()
+
+	changed := false
+	for _, item := range items {
+
+		log.Printf("[DEBUG] Running rule %s with item %s\n", rule.Name, item)
+		e.env.Set("item", item)
+
+		c, err := e.executeModule(helper, rule, map[string]interface{}{"target": item})
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+
+	return changed, nil
+}
+
+// executeModule expands the parameters of the given rule, then checks and
+// executes them with the module.
+//
+// Any overrides replace the expanded parameters of the same name.
+func (e *Executor) executeModule(helper modules.ModuleAPI, rule *ast.Rule, overrides map[string]interface{}) (bool, error) {
+
+	params, err := e.expandParams(rule)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range overrides {
+		params[k] = v
+	}
+
+	// Check the arguments, using the module-specific Check method.
+	err = helper.Check(params)
+	if err != nil {
+		return false, &ModuleError{Rule: rule.Name, Type: rule.Type, Validating: true, Cause: err}
+	}
+
+	// Execute the module.
+	changed, err := helper.Execute(params)
+	if err != nil {
+		return false, &ModuleError{Rule: rule.Name, Type: rule.Type, Cause: err}
+	}
+
+	return changed, nil
+}
+
+// expandParams expands all the parameters of the given rule into
+// strings/arrays of strings, in a new map.  We leave the rule-params
+// alone.
+func (e *Executor) expandParams(rule *ast.Rule) (map[string]interface{}, error) {
+
+	params := make(map[string]interface{})
+
+	// So for each argument
+	for k, v := range rule.Params {
+
+		// The variables have already been handled, and the
+		// changed_when expression is evaluated after execution.
+		if k == "with" || k == "changed_when" {
+			continue
+		}
+
+		// Is this parameter value an array?
+		//
+		// If so expand each value it contains.
+		array, ok := v.(ast.Array)
+		if ok {
+
+			tmp, err := e.evaluateArray(array)
+			if err != nil {
+				return nil, err
+			}
+
+			params[k] = tmp
+
+			continue
+		}
+
+		// parameter contains a single node?
+		p, ok := v.(ast.Object)
+		if ok {
+
+			// Is it a reference to an array-variable?
+			if str, ok := p.(ast.String); ok {
+				if vals, ok := e.env.ExpandArray(str.Value); ok {
+					params[k] = vals
+					continue
+				}
+			}
+
+			// Is it a single node, which we can convert?
+			val, err := p.Evaluate(e.env)
+			if err != nil {
+				return nil, err
+			}
+			params[k] = val
+
+			continue
+		}
+
+		// We got a parameter which is unknown
+		return nil, fmt.Errorf("runInternalModule unknown object at deps - %V %T", v, v)
+
+	}
+
+	return params, nil
+}
+
+// changedWhen evaluates the changed_when expression of the given rule,
+// which decides whether the rule made a change.
+func (e *Executor) changedWhen(rule *ast.Rule, expr interface{}) (bool, error) {
+
+	obj, ok := expr.(ast.Object)
+	if !ok {
+		return false, fmt.Errorf("changed_when for rule '%s' must be a single value, got %v", rule.Name, expr)
+	}
+
+	ret, err := obj.Evaluate(e.env)
+	if err != nil {
+		return false, &ConditionError{Rule: rule.Name, Condition: "changed_when", Cause: err}
+	}
+
+	log.Printf("[DEBUG] changed_when for rule %s returned '%s'", rule.Name, ret)
+
+	// Is the result "truthy"?
+	if ret == "" ||
+		ret == "false" ||
+		ret == "0" {
+		return false, nil
+	}
+	return true, nil
+}
@@ -1,9 +1,17 @@
 package executor
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
@@ -763,3 +771,1261 @@ file triggered {
 		os.Remove(f)
 	}
 }
+
+// runSource parses and executes the given source.
+func runSource(src string) error {
+
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		return err
+	}
+
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		return err
+	}
+
+	return ex.Execute()
+}
+
+// TestIncludeDir ensures the rule-files in a directory are included,
+// in order, and only once.
+func TestIncludeDir(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	// Each file appends its name to our output file.
+	conf := filepath.Join(dir, "conf.d")
+	err = os.Mkdir(conf, 0755)
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	for _, name := range []string{"b.rules", "a.recipe", "c.hcl", "ignored.txt"} {
+		src := `shell { command => "echo ` + name + ` >> ` + output + `" }`
+		err = ioutil.WriteFile(filepath.Join(conf, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	err = runSource(`include_dir "` + conf + `"
+include_dir "` + conf + `" if exists("` + conf + `")
+include_dir "` + conf + `" unless exists("` + conf + `")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "a.recipe\nb.rules\nc.hcl\n" {
+		t.Fatalf("wrong files included: %q", data)
+	}
+
+	// Including something that isn't a directory fails.
+	err = runSource(`include_dir "` + output + `"`)
+	if err == nil {
+		t.Fatalf("expected error including a file as a directory")
+	}
+}
+
+// TestIncludeGlob ensures that glob-patterns may be included, and that
+// each matching file is processed exactly once.
+func TestIncludeGlob(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	// Each file appends its name to our output file.
+	for _, name := range []string{"two.rules", "one.rules", "three.txt"} {
+		src := `shell { command => "echo ` + name + ` >> ` + output + `" }`
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	pattern := filepath.Join(dir, "*.rules")
+	err = runSource(`include "` + pattern + `"
+include [ "` + pattern + `", "` + filepath.Join(dir, "one.rules") + `" ]
+include "` + filepath.Join(dir, "*.missing") + `"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "one.rules\ntwo.rules\n" {
+		t.Fatalf("wrong files included: %q", data)
+	}
+}
+
+// TestForeach ensures the rules within a loop are executed once for
+// each value.
+func TestForeach(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+foreach host in [ "a", "b" ] {
+   let path = "` + dir + `/${host}.conf"
+   file { name => "conf", target => "${path}", content => "${host}" }
+   shell triggered { name => "restart", command => "echo ${host} >> ` + output + `" }
+   log { message => "${host}", notify => "restart", require => "conf" }
+}
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Each file should have been created.
+	for _, host := range []string{"a", "b"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, host+".conf"))
+		if err != nil {
+			t.Fatalf("failed to read file: %s", err)
+		}
+		if string(data) != host {
+			t.Fatalf("wrong content for %s: %s", host, data)
+		}
+	}
+
+	// Each notification should have been delivered to the rule in the
+	// same iteration.
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "a\nb\n" {
+		t.Fatalf("wrong output: %q", data)
+	}
+}
+
+// TestNotifyOnce ensures that a rule notified by several others only
+// runs once.
+func TestNotifyOnce(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+log { message => "one",   notify => "handler" }
+log { message => "two",   notify => "handler" }
+log { message => "three", notify => "handler" }
+
+# notifies the handler, while the handler is running
+log { name => "dependency", message => "dep", notify => "handler" }
+
+shell triggered { name    => "handler",
+                  command => "echo ran >> ` + output + `",
+                  require => "dependency" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "ran\n" {
+		t.Fatalf("handler should have run once: %q", data)
+	}
+}
+
+// TestRequireTriggered ensures that a triggered rule isn't run because
+// another rule requires it, and that we warn about that.
+func TestRequireTriggered(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	// Capture our log messages
+	before := log.Writer()
+	defer log.SetOutput(before)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	src := `
+shell triggered { name    => "handler",
+                  command => "echo handler >> ` + output + `" }
+
+shell { name    => "main",
+        command => "echo main >> ` + output + `",
+        require => "handler" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "main\n" {
+		t.Fatalf("triggered rule ran via require: %q", data)
+	}
+
+	if !strings.Contains(buf.String(), "[WARN] rule 'main' requires 'handler'") {
+		t.Fatalf("expected a warning, got %s", buf.String())
+	}
+
+	// Notifying the rule still works, even though it was
+	// previously skipped.
+	err = os.Remove(output)
+	if err != nil {
+		t.Fatalf("failed to remove output: %s", err)
+	}
+
+	src += `
+shell { command => "true", notify => "handler", require => "main" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err = ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "main\nhandler\n" {
+		t.Fatalf("triggered rule didn't run via notify: %q", data)
+	}
+}
+
+// TestSetVariable ensures variables may be set before execution, and
+// replaced by the rules.
+func TestSetVariable(t *testing.T) {
+
+	src := `
+assert { that => equal("${name}", "steve"), message => "name" }
+assert { that => equal("${path}", "a=b"), message => "path" }
+
+let name = "bob"
+assert { that => equal("${name}", "bob"), message => "let" }
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("failed to check rules:%s", err)
+	}
+
+	ex.SetVariable("name", "steve")
+	ex.SetVariable("path", "a=b")
+
+	err = ex.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// TestAtExit ensures that at_exit rules run after everything else, even
+// if an earlier rule failed.
+func TestAtExit(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	tmp := filepath.Join(dir, "tmp")
+
+	src := `
+directory { target => "` + tmp + `" }
+
+directory at_exit { name   => "cleanup",
+                    target => "` + tmp + `",
+                    state  => "absent" }
+
+shell at_exit { command => "echo exit >> ` + output + `" }
+
+shell { command => "echo main >> ` + output + `" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "main\nexit\n" {
+		t.Fatalf("at_exit rule ran at the wrong time: %q", data)
+	}
+	if file.Exists(tmp) {
+		t.Fatalf("cleanup rule didn't run")
+	}
+
+	// Now with a failure before the cleanup is declared
+	src = `
+directory { target => "` + tmp + `" }
+shell { command => "false" }
+
+directory at_exit { target => "` + tmp + `", state => "absent" }
+`
+	err = runSource(src)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if file.Exists(tmp) {
+		t.Fatalf("cleanup rule didn't run after a failure")
+	}
+
+	// A failing at_exit rule is reported, and doesn't stop others
+	err = os.Remove(output)
+	if err != nil {
+		t.Fatalf("failed to remove output: %s", err)
+	}
+
+	src = `
+shell at_exit { name => "broken", command => "false" }
+shell at_exit { command => "echo exit >> ` + output + `" }
+`
+	err = runSource(src)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "at_exit rule broken failed") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+	data, err = ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "exit\n" {
+		t.Fatalf("at_exit rule didn't run after a failure: %q", data)
+	}
+}
+
+// TestRuleVariables ensures that variables may be set for the duration
+// of a single rule.
+func TestRuleVariables(t *testing.T) {
+
+	src := `
+let tag = "latest"
+
+assert { that    => equal("${tag}-${other}", "v1-a=b"),
+         message => "with",
+         with    => [ "tag=v1", "other=a=b" ] }
+
+assert { that    => equal("${tag}${other}", "latest"),
+         message => "restored" }
+
+assert { that    => equal("${tag}", "latest-extra"),
+         message => "expanded",
+         with    => "tag=${tag}-extra" }
+`
+	err := runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus values are an error
+	err = runSource(`log { message => "hi", with => "steve" }`)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "key=value") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+}
+
+// TestArrayVariables ensures that arrays may be stored in variables, and
+// used as array parameters.
+func TestArrayVariables(t *testing.T) {
+
+	src := `
+let name = "steve"
+let pkgs = [ "git", "${name}" ]
+
+assert { that    => equal("pkgs: ${pkgs}", "pkgs: git,steve"),
+         message => "string" }
+
+fail { message => "${pkgs}", name => "single" }
+`
+	err := runSource(src)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "git\nsteve") {
+		t.Fatalf("array wasn't expanded: %s", err)
+	}
+
+	// Arrays are spliced into other arrays
+	src = `
+let pkgs = [ "git", "curl" ]
+fail { message => [ "vim", "${pkgs}", "${pkgs}.x" ] }
+`
+	err = runSource(src)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "vim\ngit\ncurl\ngit,curl.x") {
+		t.Fatalf("array wasn't spliced: %s", err)
+	}
+}
+
+// TestGraph ensures we can output a dependency graph.
+func TestGraph(t *testing.T) {
+
+	src := `
+log { name => "one", message => "one", notify => "two" }
+log triggered { name => "two", message => "two" }
+log { name => "three", message => "three", require => [ "one" ] }
+log { name => "four", message => "four", description => "The fourth rule" }
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("failed to check rules:%s", err)
+	}
+
+	var buf strings.Builder
+	err = ex.Graph(&buf)
+	if err != nil {
+		t.Fatalf("failed to graph rules:%s", err)
+	}
+
+	graph := buf.String()
+	expected := []string{
+		"digraph rules {",
+		`"one" [label="log: one"];`,
+		`"two" [label="log: two", style=dashed];`,
+		`"one" -> "two" [label="notify"];`,
+		`"three" -> "one" [label="require"];`,
+		`"four" [label="log: four\nThe fourth rule"];`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(graph, e) {
+			t.Fatalf("graph didn't contain %s: %s", e, graph)
+		}
+	}
+}
+
+// TestList ensures the rules are listed, along with their descriptions.
+func TestList(t *testing.T) {
+
+	src := `
+let x = "unused"
+log { name => "one", message => "one" }
+log triggered { name => "two", message => "two", description => "The second rule" }
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("failed to check rules:%s", err)
+	}
+
+	var buf strings.Builder
+	ex.List(&buf)
+
+	expected := "log one\nlog two - The second rule\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected listing: %q", buf.String())
+	}
+}
+
+// TestStrict ensures unknown parameters are rejected in strict mode.
+func TestStrict(t *testing.T) {
+
+	type TestCase struct {
+		Source string
+		Error  string
+	}
+
+	tests := []TestCase{
+		{Source: `file { target => "/tmp/x", conent => "x" }`, Error: "unknown parameter 'conent'"},
+		{Source: `shell { name => "s", command => "true", comand => "true" }`, Error: "unknown parameter 'comand'"},
+		{Source: `directory { target => "/tmp/x", description => "ok", moed => "0755" }`, Error: "unknown parameter 'moed'"},
+		{Source: `file { target => "/tmp/x", content => "x", require => "other", description => "ok" }
+shell { name => "other", command => "true", changed_when => "false", with => [ "a=b" ] }`},
+		{Source: `log { message => "modules without KnownParams are not checked", bogus => "x" }`},
+	}
+
+	for _, test := range tests {
+
+		p := parser.New(test.Source)
+		out, err := p.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// Without strict-mode anything goes.
+		ex := New(out.Recipe)
+		err = ex.Check()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		ex = New(out.Recipe)
+		ex.SetConfig(&config.Config{Strict: true})
+		err = ex.Check()
+
+		if test.Error == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", test.Source, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("expected error for %s", test.Source)
+		}
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Fatalf("got error - but wrong one : %s", err)
+		}
+
+		var me *ModuleError
+		if !errors.As(err, &me) || !me.Validating {
+			t.Fatalf("expected validating module error, got %T", err)
+		}
+	}
+}
+
+// TestCheckCalls ensures function-calls with the wrong number of
+// arguments are rejected before anything is executed.
+func TestCheckCalls(t *testing.T) {
+
+	type TestCase struct {
+		Source string
+		Error  string
+	}
+
+	tests := []TestCase{
+		{Source: `shell { name => "one", command => "true", if => equal("foo") }`,
+			Error: "error in rule 'one': wrong number of args for 'equal': 1, expected 2"},
+		{Source: `shell { name => "two", command => "true", unless => not(exists("a", "b")) }`,
+			Error: "error in rule 'two': wrong number of args for 'exists': 2, expected 1"},
+		{Source: `shell { name => "three", command => "true", changed_when => rand("1") }`,
+			Error: "error in rule 'three': wrong number of args for 'rand': 1, expected 2 to 3"},
+		{Source: `shell { name => "four", command => [ "true", upper() ] }`,
+			Error: "error in rule 'four': wrong number of args for 'upper': 0, expected 1"},
+		{Source: `let x = and() `,
+			Error: "error in assignment to 'x': wrong number of args for 'and': 0, expected at least 1"},
+		{Source: `let x = "y" if bogus("a")`,
+			Error: "error in assignment to 'x': function bogus not defined"},
+		{Source: `include "/tmp/x" unless is_file()`,
+			Error: "wrong number of args for 'is_file': 0, expected 1"},
+		{Source: `shell { command => "true", if => and(equal("a", "b"), on_path("sh")) }`},
+	}
+
+	for _, test := range tests {
+
+		p := parser.New(test.Source)
+		out, err := p.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", test.Source, err)
+		}
+
+		ex := New(out.Recipe)
+		err = ex.Check()
+
+		if test.Error == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", test.Source, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("expected error for %s", test.Source)
+		}
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Fatalf("got error - but wrong one : %s", err)
+		}
+	}
+}
+
+// TestItems ensures file and directory rules with several targets are
+// applied to each, with ${item} set to the current target.
+func TestItems(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	src := `
+let item = "original"
+let files = [ "${DIR}/a", "${DIR}/b" ]
+
+directory { target => [ "${DIR}/one", "${DIR}/two" ] }
+file { target => "${files}", content => "path is ${item}" }
+file { name => "last", target => "${DIR}/c", content => "item is ${item}" }
+`
+	src = strings.ReplaceAll(src, "${DIR}", dir)
+
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, name := range []string{"one", "two"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || !info.IsDir() {
+			t.Fatalf("directory %s wasn't created", name)
+		}
+	}
+
+	expected := map[string]string{
+		"a": "path is " + filepath.Join(dir, "a"),
+		"b": "path is " + filepath.Join(dir, "b"),
+		"c": "item is original",
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", name, err)
+		}
+		if string(data) != content {
+			t.Fatalf("unexpected content for %s: %q", name, data)
+		}
+	}
+}
+
+// TestKeepGoing ensures we can continue past failures, skipping only the
+// rules which depend upon the failing ones.
+func TestKeepGoing(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+shell { name    => "broken",
+        command => "false" }
+
+shell { name    => "dependent",
+        command => "echo dependent >> ` + output + `",
+        require => "broken" }
+
+shell { name    => "independent",
+        command => "echo independent >> ` + output + `" }
+`
+
+	// By default we stop at the first failure.
+	err = runSource(src)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if file.Exists(output) {
+		t.Fatalf("rules ran after a failure")
+	}
+
+	// Now continue past failures
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	ex.SetConfig(&config.Config{KeepGoing: true})
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ex.Execute()
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "2 rule(s) failed: broken, dependent") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "independent\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+}
+
+// TestKeepGoingInclude ensures we continue past a failing include.
+func TestKeepGoingInclude(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	broken := filepath.Join(dir, "broken.recipe")
+
+	err = ioutil.WriteFile(broken, []byte(`shell { name => "inner", command => "false" }`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	src := `
+include "` + broken + `"
+include_dir "` + filepath.Join(dir, "missing") + `"
+
+shell { name    => "later",
+        command => "echo later >> ` + output + `" }
+`
+
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	ex.SetConfig(&config.Config{KeepGoing: true})
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ex.Execute()
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "include "+broken) {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("later rule didn't run: %s", err)
+	}
+	if string(data) != "later\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+}
+
+// TestErrorTypes ensures the errors we return can be identified.
+func TestErrorTypes(t *testing.T) {
+
+	// Missing dependency
+	err := runSource(`shell { command => "true", require => "missing" }`)
+	var dErr *DependencyError
+	if !errors.As(err, &dErr) {
+		t.Fatalf("expected a DependencyError, got %v", err)
+	}
+	if dErr.Dependency != "missing" || dErr.Failed {
+		t.Fatalf("unexpected error contents: %v", dErr)
+	}
+
+	// Module failure
+	err = runSource(`shell { name => "broken", command => "false" }`)
+	var mErr *ModuleError
+	if !errors.As(err, &mErr) {
+		t.Fatalf("expected a ModuleError, got %v", err)
+	}
+	if mErr.Rule != "broken" || mErr.Type != "shell" || mErr.Validating {
+		t.Fatalf("unexpected error contents: %v", mErr)
+	}
+	if !strings.Contains(err.Error(), "error running shell-module rule 'broken'") {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+
+	// Module validation failure
+	err = runSource(`shell { name => "invalid" }`)
+	if !errors.As(err, &mErr) {
+		t.Fatalf("expected a ModuleError, got %v", err)
+	}
+	if !mErr.Validating {
+		t.Fatalf("expected a validation failure: %v", mErr)
+	}
+
+	// Condition failure
+	err = runSource(`shell { name => "cond", command => "true", if => rand("10", "1") }`)
+	var cErr *ConditionError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("expected a ConditionError, got %v", err)
+	}
+	if cErr.Rule != "cond" || cErr.Condition != "if" {
+		t.Fatalf("unexpected error contents: %v", cErr)
+	}
+
+	// A requirement which has already failed
+	p := parser.New(`
+shell { name => "broken", command => "false" }
+shell { name => "child", command => "true", require => "broken" }
+`)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ex.executeSingleRule(ex.Program[0].(*ast.Rule), false)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+
+	err = ex.executeSingleRule(ex.Program[1].(*ast.Rule), false)
+	if !errors.As(err, &dErr) || !dErr.Failed {
+		t.Fatalf("expected a failed DependencyError, got %v", err)
+	}
+}
+
+// TestErrorTypesInclude ensures that errors within included files can
+// still be told apart.
+func TestErrorTypesInclude(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	broken := filepath.Join(dir, "broken.recipe")
+	err = ioutil.WriteFile(broken, []byte(`shell { name => "inner", command => "false" }`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.recipe")
+	err = ioutil.WriteFile(invalid, []byte(`shell { name => `), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	// Module failure
+	err = runSource(`include "` + broken + `"`)
+	var mErr *ModuleError
+	if !errors.As(err, &mErr) {
+		t.Fatalf("expected a ModuleError, got %v", err)
+	}
+	if mErr.Rule != "inner" || mErr.Type != "shell" {
+		t.Fatalf("unexpected error contents: %v", mErr)
+	}
+
+	// Parse failure
+	err = runSource(`include "` + invalid + `"`)
+	var pErr *ParseError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if pErr.File != invalid {
+		t.Fatalf("unexpected error contents: %v", pErr)
+	}
+
+	// Parse failure of the recipe itself
+	_, err = newFromSource(`shell { name => `, "", nil)
+	if !errors.As(err, &pErr) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+}
+
+// TestProgress ensures we show our progress, when configured to.
+func TestProgress(t *testing.T) {
+
+	// Capture our log messages
+	before := log.Writer()
+	defer log.SetOutput(before)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	src := `
+shell { name => "one", command => "true", require => "two" }
+shell { name => "two", command => "true", notify => "handler" }
+shell triggered { name => "handler", command => "true" }
+shell { name => "three", command => "true", if => equal("a", "b") }
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	ex.SetConfig(&config.Config{Progress: true})
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = ex.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var progress []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "[USER]") {
+			progress = append(progress, line[strings.Index(line, "[USER]"):])
+		}
+	}
+
+	expected := []string{
+		"[USER] [1/3] running rule two",
+		"[USER] [2/3] running rule one",
+		"[USER] [3/3] running rule three",
+	}
+	if strings.Join(progress, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected progress:\n%s", strings.Join(progress, "\n"))
+	}
+}
+
+// TestChangedWhen ensures a rule's changed_when expression can override
+// the change reported by the module.
+func TestChangedWhen(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+shell { name         => "unchanged",
+        command      => "echo NOTHING",
+        changed_when => contains("${unchanged.stdout}", "UPDATED"),
+        notify       => "handler" }
+
+shell { name         => "updated",
+        command      => "echo UPDATED",
+        changed_when => contains("${updated.stdout}", "UPDATED"),
+        notify       => "other" }
+
+shell triggered { name    => "handler",
+                  command => "echo handler >> ` + output + `" }
+
+shell triggered { name    => "other",
+                  command => "echo other >> ` + output + `" }
+
+shell { command => "echo ${unchanged.changed} ${updated.changed} >> ` + output + `" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "other\nfalse true\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+
+	// Errors are reported
+	err = runSource(`shell { command => "true", changed_when => rand("10", "1") }`)
+	var cErr *ConditionError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("expected a ConditionError, got %v", err)
+	}
+}
+
+// TestChangedWhenSelf ensures a rule can refer to its own outputs via
+// the "self." prefix, within its changed_when expression.
+func TestChangedWhenSelf(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+shell { command      => "echo UPDATED",
+        changed_when => contains("${self.stdout}", "UPDATED"),
+        notify       => "updated" }
+
+shell { command      => "echo NOTHING",
+        changed_when => and("${self.changed}", contains("${self.stdout}", "UPDATED")),
+        notify       => "unchanged" }
+
+shell triggered { name    => "updated",
+                  command => "echo updated >> ` + output + `" }
+
+shell triggered { name    => "unchanged",
+                  command => "echo unchanged >> ` + output + `" }
+
+shell { command => "echo [${self.stdout}] >> ` + output + `" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+
+	// The self-variables are not visible outside the rule.
+	if string(data) != "updated\n[]\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+}
+
+// TestRegister ensures the outputs of a rule may be registered under a
+// name of our choosing.
+func TestRegister(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+shell { command  => "echo hello",
+        register => "greeting" }
+
+shell { name     => "named",
+        command  => "echo world",
+        register => "other" }
+
+shell { command => "echo ${greeting.stdout} ${other.stdout} ${named.stdout} ${greeting.changed} > ` + output + `" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "hello world world true\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+
+	// Arrays are not valid names
+	err = runSource(`shell { command => "true", register => [ "a", "b" ] }`)
+	if err == nil {
+		t.Fatalf("expected error with array register")
+	}
+	if !strings.Contains(err.Error(), "must be a single value") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+}
+
+// TestOS ensures rules are skipped upon operating systems they don't
+// apply to.
+func TestOS(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+
+	src := `
+let systems = [ "plan9", "` + runtime.GOOS + `" ]
+
+shell { command => "echo one >> ` + output + `", os => "` + runtime.GOOS + `" }
+shell { command => "echo two >> ` + output + `", os => [ "plan9", "aix" ] }
+shell { command => "echo three >> ` + output + `", os => "${systems}" }
+shell { command => "echo four >> ` + output + `", os => "` + runtime.GOOS + `", if => equal("a", "b") }
+shell { command => "echo five >> ` + output + `" }
+`
+	err = runSource(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != "one\nthree\nfive\n" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+}
+
+// TestChanges ensures that the rules which made changes are recorded,
+// including those within included files.
+func TestChanges(t *testing.T) {
+
+	tmp, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(`shell { name => "included", command => "true" }`)
+	if err != nil {
+		t.Fatalf("failed to write temporary file: %s", err)
+	}
+	tmp.Close()
+
+	src := `
+shell { name => "one", command => "true" }
+shell { name => "two", command => "true", changed_when => "false" }
+shell triggered { name => "three", command => "true" }
+include "` + tmp.Name() + `"
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ex := New(out.Recipe)
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = ex.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []Change{
+		{Name: "one", Type: "shell"},
+		{Name: "included", Type: "shell"},
+	}
+	if !reflect.DeepEqual(ex.Changes(), expected) {
+		t.Fatalf("unexpected changes: %v", ex.Changes())
+	}
+}
+
+// TestState ensures that only the variables assigned by `let` are
+// saved, so that the state-file doesn't grow from run to run.
+func TestState(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	src := `
+let name = "steve"
+let pkgs = [ "a", "b" ]
+shell { command => "true" }
+shell { name => "named", command => "true" }
+`
+
+	// Run the recipe, returning the keys which were saved.
+	run := func() []string {
+
+		p := parser.New(src)
+		out, err := p.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		ex := New(out.Recipe)
+		err = ex.Check()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = ex.LoadState(path)
+		if err != nil {
+			t.Fatalf("failed to load state: %s", err)
+		}
+		err = ex.Execute()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = ex.SaveState(path)
+		if err != nil {
+			t.Fatalf("failed to save state: %s", err)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read state: %s", err)
+		}
+		vars := make(map[string]string)
+		err = json.Unmarshal(data, &vars)
+		if err != nil {
+			t.Fatalf("failed to parse state: %s", err)
+		}
+
+		var keys []string
+		for key := range vars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	first := run()
+	second := run()
+
+	expected := []string{"name", "pkgs"}
+	if !reflect.DeepEqual(first, expected) {
+		t.Fatalf("unexpected state after the first run: %v", first)
+	}
+	if !reflect.DeepEqual(second, expected) {
+		t.Fatalf("unexpected state after the second run: %v", second)
+	}
+}
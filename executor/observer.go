@@ -0,0 +1,25 @@
+package executor
+
+// Observer is the interface which may be implemented by programs which
+// embed the executor, to be informed as rules are executed.
+//
+// This might be used to show progress, for example.
+type Observer interface {
+
+	// RuleStart is called before a rule is executed.
+	RuleStart(name string, ruleType string)
+
+	// RuleSkipped is called when a rule is not executed, because of
+	// its condition, or because a rule it requires has failed.
+	RuleSkipped(name string, reason string)
+
+	// RuleDone is called after a rule has been executed, with the
+	// result of the execution.
+	RuleDone(name string, changed bool, err error)
+}
+
+// SetObserver sets the observer which should be notified as rules
+// are executed.
+func (e *Executor) SetObserver(observer Observer) {
+	e.observer = observer
+}
@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/parser"
+)
+
+// recorder is an Observer which records the events it sees.
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) RuleStart(name string, ruleType string) {
+	r.events = append(r.events, fmt.Sprintf("start %s %s", name, ruleType))
+}
+
+func (r *recorder) RuleSkipped(name string, reason string) {
+	r.events = append(r.events, fmt.Sprintf("skip %s", name))
+}
+
+func (r *recorder) RuleDone(name string, changed bool, err error) {
+	r.events = append(r.events, fmt.Sprintf("done %s %t %t", name, changed, err != nil))
+}
+
+func TestObserver(t *testing.T) {
+
+	src := `
+shell { name => "one", command => "true" }
+shell { name => "two", command => "true", if => equal("a", "b") }
+shell { name => "three", command => "false" }
+shell { name => "four", command => "true", require => "three" }
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	rec := &recorder{}
+
+	ex := New(out.Recipe)
+	ex.SetConfig(&config.Config{KeepGoing: true})
+	ex.SetObserver(rec)
+
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ex.Execute()
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+
+	expected := []string{
+		"start one shell",
+		"done one true false",
+		"skip two",
+		"start three shell",
+		"done three false true",
+		"skip four",
+	}
+
+	if strings.Join(rec.events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(rec.events, "\n"))
+	}
+}
+
+// TestObserverInclude ensures that rules within included files are
+// observed too.
+func TestObserverInclude(t *testing.T) {
+
+	tmp, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(`shell { name => "included", command => "true" }`)
+	if err != nil {
+		t.Fatalf("failed to write temporary file: %s", err)
+	}
+	tmp.Close()
+
+	src := `
+shell { name => "one", command => "true" }
+include "` + tmp.Name() + `"
+`
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	rec := &recorder{}
+
+	ex := New(out.Recipe)
+	ex.SetObserver(rec)
+
+	err = ex.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = ex.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"start one shell",
+		"done one true false",
+		"start included shell",
+		"done included true false",
+	}
+
+	if strings.Join(rec.events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(rec.events, "\n"))
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/parser"
+)
+
+// NewFromFile reads and parses the given file, returning an executor
+// which is ready to run the rules it contains.
+//
+// The dependencies of the rules are checked before we return.
+func NewFromFile(path string, cfg *config.Config) (*Executor, error) {
+
+	// Read the file contents.
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFromSource(string(data), path, cfg)
+}
+
+// RunFile parses, checks, and executes the rules in the given file.
+//
+// This is the simplest way to embed marionette within another program.
+// If cfg is nil the default configuration is used.
+func RunFile(path string, cfg *config.Config) error {
+
+	ex, err := NewFromFile(path, cfg)
+	if err != nil {
+		return err
+	}
+
+	return ex.Execute()
+}
+
+// Run parses, checks, and executes the rules read from the given reader.
+//
+// If cfg is nil the default configuration is used.
+func Run(reader io.Reader, cfg *config.Config) error {
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	ex, err := newFromSource(string(data), "", cfg)
+	if err != nil {
+		return err
+	}
+
+	return ex.Execute()
+}
+
+// newFromSource parses the given rules, and returns an executor which has
+// had the dependencies checked.
+//
+// The path is the file the rules were read from, which may be empty.
+func newFromSource(src string, path string, cfg *config.Config) (*Executor, error) {
+
+	// Parse the rules
+	p := parser.New(src)
+	out, err := p.Parse()
+	if err != nil {
+		return nil, &ParseError{File: path, Cause: err}
+	}
+
+	// Now we'll create an executor with the program
+	ex := New(out.Recipe)
+
+	// Set the configuration options.
+	if cfg != nil {
+		ex.SetConfig(cfg)
+	}
+
+	if path != "" {
+
+		// Mark the file as having been processed.
+		ex.MarkSeen(path)
+
+		// Set "magic" variables for the current include file.
+		err = ex.SetMagicIncludeVars(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Check for broken dependencies
+	err = ex.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	return ex, nil
+}
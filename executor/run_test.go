@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/marionette/config"
+)
+
+func TestRunFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	rules := filepath.Join(dir, "rules.txt")
+
+	// The magic variables are set for the file.
+	src := `file { target => "` + output + `", content => "${INCLUDE_FILE}" }`
+	err = ioutil.WriteFile(rules, []byte(src), 0644)
+	if err != nil {
+		t.Fatalf("failed to write rules: %s", err)
+	}
+
+	err = RunFile(rules, &config.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %s", err)
+	}
+	if string(data) != rules {
+		t.Fatalf("unexpected output: %s", data)
+	}
+
+	// Missing files are an error
+	err = RunFile(filepath.Join(dir, "missing"), nil)
+	if err == nil {
+		t.Fatalf("expected error running missing file")
+	}
+
+	// As are broken dependencies
+	err = ioutil.WriteFile(rules, []byte(`shell { command => "true", require => "missing" }`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write rules: %s", err)
+	}
+	err = RunFile(rules, nil)
+	if err == nil {
+		t.Fatalf("expected error running broken file")
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	err := Run(strings.NewReader(`shell { command => "true" }`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = Run(strings.NewReader(`shell { command => "false" }`), nil)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+
+	err = Run(strings.NewReader(`shell {`), nil)
+	if err == nil {
+		t.Fatalf("expected parse error, got none")
+	}
+}
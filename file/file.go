@@ -4,8 +4,12 @@ package file
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Copy copies the contents of the source file into the destination file.
@@ -31,6 +35,87 @@ func Copy(src string, dst string) error {
 	return out.Close()
 }
 
+// CopyMode copies the contents of the source file into the destination
+// file, ensuring that the destination has the given mode before any of
+// the new content is written to it.
+func CopyMode(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// The mode used when creating the file is subject to the umask,
+	// and an existing file keeps its mode, so set it explicitly.
+	err = out.Chmod(mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// Backup copies the given file to a timestamped backup, alongside the
+// original, returning the name of the backup which was created.
+//
+// The backup has the same mode as the original, and existing backups are
+// never replaced; if one already exists with the same timestamp then a
+// numeric suffix is added.
+func Backup(path string) (string, error) {
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	mode := info.Mode().Perm()
+
+	// Find a name which isn't already in use.
+	base := path + ".bak-" + time.Now().Format("20060102150405")
+	dst := base
+	var out *os.File
+	for i := 1; ; i++ {
+		out, err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		dst = fmt.Sprintf("%s.%d", base, i)
+	}
+	defer out.Close()
+
+	// The mode used when creating the file is subject to the umask.
+	err = out.Chmod(mode)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return "", err
+	}
+
+	return dst, out.Close()
+}
+
 // Exists reports whether the named file or directory exists.
 func Exists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -97,3 +182,94 @@ func Identical(a string, b string) (bool, error) {
 
 	return false, nil
 }
+
+// ParseMode converts the given mode into an os.FileMode.
+//
+// The mode may be an octal string, such as "0755", or a symbolic mode
+// such as "u+x", "go-w", or "a=r,u+w", in the style of chmod.  Symbolic
+// modes are applied relative to the current mode.
+func ParseMode(mode string, current os.FileMode) (os.FileMode, error) {
+
+	if mode == "" {
+		return 0, fmt.Errorf("empty mode")
+	}
+
+	// Octal?
+	if strings.Trim(mode, "01234567") == "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return 0, err
+		}
+		return os.FileMode(m), nil
+	}
+
+	m := current.Perm()
+
+	// Each clause is processed in turn.
+	for _, clause := range strings.Split(mode, ",") {
+
+		// Who are we changing?  Defaults to everybody.
+		var who os.FileMode
+		i := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0700
+			case 'g':
+				who |= 0070
+			case 'o':
+				who |= 0007
+			case 'a':
+				who |= 0777
+			}
+		}
+		if who == 0 {
+			who = 0777
+		}
+
+		if i == len(clause) {
+			return 0, fmt.Errorf("invalid mode '%s'", mode)
+		}
+
+		// Now each operation, and the permissions it applies.
+		for i < len(clause) {
+
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return 0, fmt.Errorf("invalid mode '%s'", mode)
+			}
+			i++
+
+			var perms os.FileMode
+			for ; i < len(clause) && strings.IndexByte("+-=", clause[i]) < 0; i++ {
+				switch clause[i] {
+				case 'r':
+					perms |= 0444
+				case 'w':
+					perms |= 0222
+				case 'x':
+					perms |= 0111
+				case 'X':
+					// Execute only for directories, or if some
+					// execute bit is already set.
+					if current.IsDir() || m&0111 != 0 {
+						perms |= 0111
+					}
+				default:
+					return 0, fmt.Errorf("unsupported permission '%c' in mode '%s'", clause[i], mode)
+				}
+			}
+
+			switch op {
+			case '+':
+				m |= perms & who
+			case '-':
+				m &^= perms & who
+			case '=':
+				m = (m &^ who) | (perms & who)
+			}
+		}
+	}
+
+	return m, nil
+}
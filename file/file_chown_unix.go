@@ -16,11 +16,6 @@ import (
 // If the mode was changed, this function will return true.
 func ChangeMode(path string, mode string) (bool, error) {
 
-	// Get the mode as an integer.
-	//
-	// NOTE: We expect octal input.
-	m, _ := strconv.ParseInt(mode, 8, 64)
-
 	// Get the details of the file, so we can see if we need
 	// to change owner, group, and mode.
 	info, err := os.Stat(path)
@@ -28,9 +23,16 @@ func ChangeMode(path string, mode string) (bool, error) {
 		return false, err
 	}
 
+	// Get the mode we want, which might be relative to the
+	// current mode.
+	m, err := ParseMode(mode, info.Mode())
+	if err != nil {
+		return false, err
+	}
+
 	// If the mode doesn't match what we expect then change it
-	if info.Mode().Perm() != os.FileMode(m) {
-		err = os.Chmod(path, os.FileMode(m))
+	if info.Mode().Perm() != m {
+		err = os.Chmod(path, m)
 		if err != nil {
 			return false, err
 		}
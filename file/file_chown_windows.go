@@ -3,17 +3,57 @@
 
 package file
 
-// ChangeMode is a NOP on Microsoft Windows
+import (
+	"log"
+	"os"
+)
+
+// ChangeMode changes the mode of the given file/directory to the
+// specified value.
+//
+// On Microsoft Windows this is best-effort, as the only permission
+// which can be changed is whether the file is writeable - so we only
+// look at the owner's write-bit.
+//
+// If the mode was changed, this function will return true.
 func ChangeMode(path string, mode string) (bool, error) {
+
+	// Get the details of the file.
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Get the mode we want, which might be relative to the
+	// current mode.
+	m, err := ParseMode(mode, info.Mode())
+	if err != nil {
+		return false, err
+	}
+
+	// If the write-permission doesn't match then change it.
+	if info.Mode().Perm()&0200 != m&0200 {
+		err = os.Chmod(path, m)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
 	return false, nil
 }
 
-// ChangeOwner is a NOP on Microsoft Windows.
-func ChangeOwner(path string, group string) (bool, error) {
+// ChangeOwner is a NOP on Microsoft Windows, as ownership changes
+// are not supported there.
+func ChangeOwner(path string, owner string) (bool, error) {
+	log.Printf("[DEBUG] Ignoring ownership change of %s to %s, this is not supported on Windows", path, owner)
 	return false, nil
 }
 
-// ChangeGroup is a NOP on Microsoft Windows.
+// ChangeGroup is a NOP on Microsoft Windows, as ownership changes
+// are not supported there.
 func ChangeGroup(path string, group string) (bool, error) {
+	log.Printf("[DEBUG] Ignoring group change of %s to %s, this is not supported on Windows", path, group)
 	return false, nil
 }
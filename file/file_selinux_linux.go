@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package file
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// selinuxMount is the location the SELinux filesystem is mounted upon,
+// if SELinux is present.
+var selinuxMount = "/sys/fs/selinux"
+
+// ChangeContext changes the SELinux security context of the given
+// file/directory to the specified value, via `chcon`.
+//
+// If SELinux is not present this does nothing.
+//
+// If the context was changed, this function will return true.
+func ChangeContext(path string, context string) (bool, error) {
+
+	if !Exists(selinuxMount) {
+		return false, nil
+	}
+
+	// Get the current context.
+	current, err := getContext(path)
+	if err != nil {
+		return false, err
+	}
+	if current == context {
+		return false, nil
+	}
+
+	out, err := exec.Command("chcon", context, path).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("error running chcon: %s %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return true, nil
+}
+
+// getContext returns the SELinux security context of the given path,
+// which is stored in an extended attribute.
+func getContext(path string) (string, error) {
+
+	// Find the size of the value, then read it.
+	size, err := syscall.Getxattr(path, "security.selinux", nil)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, "security.selinux", buf)
+	if err != nil {
+		return "", err
+	}
+
+	// The value is NUL-terminated.
+	return strings.TrimRight(string(buf[:size]), "\x00"), nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestChangeContextDisabled ensures nothing happens without SELinux.
+func TestChangeContextDisabled(t *testing.T) {
+
+	old := selinuxMount
+	defer func() { selinuxMount = old }()
+	selinuxMount = "/this/does/not/exist"
+
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	changed, err := ChangeContext(tmpfile.Name(), "system_u:object_r:etc_t:s0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change without SELinux")
+	}
+}
+
+// TestGetContext ensures a missing context is an error.
+func TestGetContext(t *testing.T) {
+
+	_, err := getContext("/this/does/not/exist")
+	if err == nil {
+		t.Fatalf("expected error reading missing file")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package file
+
+// ChangeContext changes the SELinux security context of the given
+// file/directory to the specified value.
+//
+// SELinux is only available upon Linux, so this does nothing.
+func ChangeContext(path string, context string) (bool, error) {
+	return false, nil
+}
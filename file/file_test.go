@@ -3,6 +3,8 @@ package file
 import (
 	"io/ioutil"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -168,3 +170,176 @@ func TestCopy(t *testing.T) {
 	os.Remove(a.Name())
 	os.Remove(b.Name())
 }
+
+// TestBackup ensures that a backup has the same content as the original.
+func TestBackup(t *testing.T) {
+
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	_, err = tmpfile.Write([]byte("backup test"))
+	if err != nil {
+		t.Fatalf("error writing temporary file")
+	}
+	tmpfile.Close()
+
+	bak, err := Backup(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("unexpected error making backup: %s", err)
+	}
+	defer os.Remove(bak)
+
+	if !strings.HasPrefix(bak, tmpfile.Name()+".bak-") {
+		t.Fatalf("backup has the wrong name: %s", bak)
+	}
+
+	same, err := Identical(tmpfile.Name(), bak)
+	if err != nil {
+		t.Fatalf("unexpected error comparing files: %s", err)
+	}
+	if !same {
+		t.Fatalf("backup differs from the original")
+	}
+
+	// A second backup, within the same second, doesn't replace
+	// the first.
+	bak2, err := Backup(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("unexpected error making backup: %s", err)
+	}
+	defer os.Remove(bak2)
+
+	if bak2 == bak {
+		t.Fatalf("backups have the same name: %s", bak)
+	}
+	if !Exists(bak) || !Exists(bak2) {
+		t.Fatalf("backups are missing")
+	}
+}
+
+// TestBackupMode ensures that a backup has the same mode as the original.
+func TestBackupMode(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("modes are limited upon Windows")
+	}
+
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	for _, mode := range []os.FileMode{0600, 0755} {
+
+		err = os.Chmod(tmpfile.Name(), mode)
+		if err != nil {
+			t.Fatalf("failed to change mode: %s", err)
+		}
+
+		bak, err := Backup(tmpfile.Name())
+		if err != nil {
+			t.Fatalf("unexpected error making backup: %s", err)
+		}
+		defer os.Remove(bak)
+
+		info, err := os.Stat(bak)
+		if err != nil {
+			t.Fatalf("failed to stat backup: %s", err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Fatalf("backup has mode %o, expected %o", info.Mode().Perm(), mode)
+		}
+	}
+}
+
+// TestParseMode tests converting octal and symbolic modes.
+func TestParseMode(t *testing.T) {
+
+	type Test struct {
+		mode    string
+		current os.FileMode
+		result  os.FileMode
+	}
+
+	tests := []Test{
+		{"0755", 0644, 0755},
+		{"600", 0777, 0600},
+		{"+x", 0644, 0755},
+		{"u+x", 0644, 0744},
+		{"g-w", 0664, 0644},
+		{"a+r", 0600, 0644},
+		{"go-rwx", 0755, 0700},
+		{"u=rw,go=r", 0777, 0644},
+		{"u+x-w", 0644, 0544},
+		{"a+X", 0644, 0644},
+		{"a+X", 0744, 0755},
+		{"a+X", os.ModeDir | 0644, 0755},
+	}
+
+	for _, test := range tests {
+		out, err := ParseMode(test.mode, test.current)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", test.mode, err)
+		}
+		if out != test.result {
+			t.Fatalf("wrong result for %s on %o: %o != %o", test.mode, test.current, out, test.result)
+		}
+	}
+
+	// Invalid modes
+	for _, mode := range []string{"", "0999", "u", "q+x", "u+q", "u+s", "u+x,"} {
+		_, err := ParseMode(mode, 0644)
+		if err == nil {
+			t.Fatalf("expected error parsing '%s'", mode)
+		}
+	}
+}
+
+// TestChangeModeSymbolic tests applying a symbolic mode to a file.
+func TestChangeModeSymbolic(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("modes are limited upon Windows")
+	}
+
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	err = os.Chmod(tmpfile.Name(), 0644)
+	if err != nil {
+		t.Fatalf("failed to change mode: %s", err)
+	}
+
+	changed, err := ChangeMode(tmpfile.Name(), "+x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	info, err := os.Stat(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("failed to stat file: %s", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("wrong mode: %o", info.Mode().Perm())
+	}
+
+	// Applying it again makes no change
+	changed, err = ChangeMode(tmpfile.Name(), "+x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+}
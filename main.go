@@ -4,72 +4,123 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/hashicorp/logutils"
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/executor"
-	"github.com/skx/marionette/parser"
 )
 
-func runFile(filename string, cfg *config.Config) error {
+// Exit-codes which are returned by the CLI.
+const (
+	// exitRuntime is used when a recipe fails during execution.
+	exitRuntime = 1
 
-	// Read the file contents.
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
+	// exitParse is used when a recipe cannot be read, parsed, or
+	// fails its dependency checks.
+	exitParse = 2
+)
+
+// varFlags holds the variables set via repeated -var flags.
+type varFlags []string
+
+// String returns the flag values, as a string.
+func (v *varFlags) String() string {
+	return strings.Join(*v, ",")
+}
+
+// Set adds another variable, which must be in key=value form.
+func (v *varFlags) Set(value string) error {
+	if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") {
+		return fmt.Errorf("variables must be of the form key=value, got '%s'", value)
 	}
+	*v = append(*v, value)
+	return nil
+}
 
-	// Create a new parser with our file content.
-	p := parser.New(string(data))
+// runFile parses and executes the given file.
+//
+// Any variables given on the command-line are set before execution.
+//
+// If a state-file is specified then variables are loaded from it before
+// execution, and saved to it afterwards.
+//
+// If changes is true then the type and name of each rule which resulted
+// in a change are printed once execution has finished.
+//
+// If an error is returned then so is the exit-code the process should
+// terminate with.
+func runFile(filename string, cfg *config.Config, state string, vars varFlags, changes bool) (int, error) {
 
-	// Parse the rules
-	out, err := p.Parse()
+	// Parse the file
+	ex, err := executor.NewFromFile(filename, cfg)
 	if err != nil {
-		return err
+		return exitParse, err
 	}
 
-	// Now we'll create an executor with the program
-	ex := executor.New(out.Recipe)
+	// Set any variables from the command-line, splitting
+	// on the first "=" only.
+	for _, v := range vars {
+		kv := strings.SplitN(v, "=", 2)
+		ex.SetVariable(kv[0], kv[1])
+	}
 
-	// Set the configuration options.
-	ex.SetConfig(cfg)
+	// Load any saved state.
+	if state != "" {
+		err = ex.LoadState(state)
+		if err != nil {
+			return exitParse, fmt.Errorf("failed to load state from %s: %s", state, err)
+		}
+	}
 
-	// Mark the file as having been processed.
-	ex.MarkSeen(filename)
+	// Now execute!
+	err = ex.Execute()
 
-	// Set "magic" variables for the current include file.
-	err = ex.SetMagicIncludeVars(filename)
-	if err != nil {
-		return err
+	// Show the changes we made, even if we failed part-way through.
+	if changes {
+		for _, c := range ex.Changes() {
+			fmt.Printf("%s %s\n", c.Type, c.Name)
+		}
 	}
 
-	// Check for broken dependencies
-	err = ex.Check()
 	if err != nil {
-		return err
+		return exitRuntime, err
 	}
 
-	// Now execute!
-	err = ex.Execute()
-	if err != nil {
-		return err
+	// Save the updated state.
+	if state != "" {
+		err = ex.SaveState(state)
+		if err != nil {
+			return exitRuntime, fmt.Errorf("failed to save state to %s: %s", state, err)
+		}
 	}
 
-	return nil
+	return 0, nil
 }
 
 // main is our entry-point
 func main() {
 
 	// Parse our command-line flags.
+	check := flag.Bool("check", false, "Parse and check the given file(s), but don't execute them.")
 	dL := flag.Bool("dl", false, "Debug the lexer?")
 	dP := flag.Bool("dp", false, "Debug the parser?")
+	list := flag.Bool("list", false, "List the rules within the given file(s), but don't execute them.")
+	dot := flag.Bool("dot", false, "Output the dependency graph of the given file(s) in graphviz format, but don't execute them.")
 
+	changes := flag.Bool("changes", false, "Show the type and name of each rule which resulted in a change, after execution.")
+	color := flag.String("color", "auto", "Colorize the log output; one of 'auto', 'always', or 'never'.")
 	decimal := flag.Bool("decimal", true, "Convert numbers to decimal, automatically.")
 	debug := flag.Bool("debug", false, "Be very verbose in logging.")
+	keepGoing := flag.Bool("keep-going", false, "Continue past rule failures, skipping the rules which depend upon them.")
+	progress := flag.Bool("progress", false, "Show how many rules have been processed.")
+	strict := flag.Bool("strict", false, "Reject rules which use parameters their module doesn't recognize.")
+	state := flag.String("state-file", "", "Load variables from, and save them to, the given file.")
+	var vars varFlags
+	flag.Var(&vars, "var", "Set a variable, as key=value.  May be repeated.")
 	verbose := flag.Bool("verbose", false, "Show logs when executing.")
 	version := flag.Bool("version", false, "Show our version number.")
 	flag.Parse()
@@ -117,18 +168,31 @@ func main() {
 		lvl = dbg
 	}
 
+	// Colorize our output, if we should.
+	if *color != "auto" && *color != "always" && *color != "never" {
+		fmt.Printf("Error:-color must be one of 'auto', 'always', or 'never', got '%s'\n", *color)
+		os.Exit(exitParse)
+	}
+	var writer io.Writer = os.Stderr
+	if useColor(*color) {
+		writer = &colorWriter{out: os.Stderr}
+	}
+
 	// Setup the filter
 	filter := &logutils.LevelFilter{
-		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "USER", "ERROR"},
+		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "USER", "WARN", "ERROR"},
 		MinLevel: lvl,
-		Writer:   os.Stderr,
+		Writer:   writer,
 	}
 	log.SetOutput(filter)
 
 	// Create our configuration object
 	cfg := &config.Config{
-		Debug:   *debug,
-		Verbose: *verbose,
+		Debug:     *debug,
+		KeepGoing: *keepGoing,
+		Progress:  *progress,
+		Strict:    *strict,
+		Verbose:   *verbose,
 	}
 
 	// Ensure we got at least one recipe to execute.
@@ -141,12 +205,64 @@ func main() {
 		return
 	}
 
+	// If we're only checking the syntax then do so, and exit.
+	if *check {
+		failed := false
+
+		for _, file := range flag.Args() {
+			_, err := executor.NewFromFile(file, cfg)
+			if err != nil {
+				fmt.Printf("%s: Error:%s\n", file, err.Error())
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: OK\n", file)
+		}
+
+		if failed {
+			os.Exit(exitParse)
+		}
+		return
+	}
+
+	// If we're outputting the dependency graph then do so, and exit.
+	if *dot {
+		for _, file := range flag.Args() {
+			ex, err := executor.NewFromFile(file, cfg)
+			if err != nil {
+				fmt.Printf("Error:%s\n", err.Error())
+				os.Exit(exitParse)
+			}
+
+			err = ex.Graph(os.Stdout)
+			if err != nil {
+				fmt.Printf("Error:%s\n", err.Error())
+				os.Exit(exitParse)
+			}
+		}
+		return
+	}
+
+	// If we're listing the rules then do so, and exit.
+	if *list {
+		for _, file := range flag.Args() {
+			ex, err := executor.NewFromFile(file, cfg)
+			if err != nil {
+				fmt.Printf("Error:%s\n", err.Error())
+				os.Exit(exitParse)
+			}
+
+			ex.List(os.Stdout)
+		}
+		return
+	}
+
 	// Process each given file.
 	for _, file := range flag.Args() {
-		err := runFile(file, cfg)
+		code, err := runFile(file, cfg, *state, vars, *changes)
 		if err != nil {
 			fmt.Printf("Error:%s\n", err.Error())
-			return
+			os.Exit(code)
 		}
 	}
 
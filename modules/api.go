@@ -53,6 +53,21 @@ type ModuleOutput interface {
 	GetOutputs() map[string]string
 }
 
+// ModuleParams is an optional interface that may be implemented by any of
+// our internal modules.
+//
+// If this interface is implemented then rules which use parameters the
+// module doesn't recognize will be rejected, when running in strict mode.
+type ModuleParams interface {
+
+	// KnownParams returns the names of the parameters the module
+	// understands.
+	//
+	// The magic keys which are supported by all modules, such as
+	// "name", "require", and "notify", should not be included.
+	KnownParams() []string
+}
+
 // StringParam returns the named parameter, as a string, from the map.
 //
 // If the parameter was not present an empty array is returned.
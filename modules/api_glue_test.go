@@ -26,7 +26,7 @@ func TestModules(t *testing.T) {
 	}
 
 	count := len(modules)
-	if count != 16 {
+	if count != 28 {
 		t.Fatalf("unexpected number of modules: %d", len(modules))
 	}
 
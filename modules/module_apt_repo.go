@@ -0,0 +1,174 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+	"github.com/skx/marionette/modules/system"
+)
+
+// AptRepoModule stores our state.
+type AptRepoModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+
+	// sources is the directory our source-lists are written to.
+	sources string
+
+	// keyrings is the directory signing keys are written to.
+	keyrings string
+}
+
+// Check is part of the module-api, and checks arguments.
+func (a *AptRepoModule) Check(args map[string]interface{}) error {
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	// Required keys for this module
+	required := []string{"name"}
+	if state == "present" {
+		required = append(required, "uri", "suite")
+	}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	// The name is used as a filename.
+	name := StringParam(args, "name")
+	if strings.ContainsAny(name, "/ ") {
+		return fmt.Errorf("invalid repository name '%s'", name)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (a *AptRepoModule) Execute(args map[string]interface{}) (bool, error) {
+
+	name := StringParam(args, "name")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	list := filepath.Join(a.sources, name+".list")
+	key := filepath.Join(a.keyrings, name+".asc")
+
+	if state == "absent" {
+		changed, err := a.remove([]string{list, key, fetchSidecar(key)})
+		if changed {
+			system.Invalidate()
+		}
+		return changed, err
+	}
+
+	// Helper for writing files.
+	helper := &FileModule{cfg: a.cfg, env: a.env}
+
+	changed := false
+
+	// Fetch the signing key, if we've been given one.
+	options := ""
+	keyURL := StringParam(args, "key_url")
+	if keyURL != "" {
+
+		err := os.MkdirAll(a.keyrings, 0755)
+		if err != nil {
+			return false, err
+		}
+
+		log.Printf("[DEBUG] Fetching signing key %s", keyURL)
+
+		changed, err = helper.FetchURL(keyURL, key)
+		if err != nil {
+			return false, err
+		}
+
+		options = fmt.Sprintf("[signed-by=%s] ", key)
+	}
+
+	// Build up the source-line.
+	components := ArrayCastParam(args, "components")
+	if len(components) == 0 {
+		components = []string{"main"}
+	}
+
+	line := fmt.Sprintf("deb %s%s %s %s\n",
+		options,
+		StringParam(args, "uri"),
+		StringParam(args, "suite"),
+		strings.Join(components, " "))
+
+	written, err := helper.CreateFile(list, line)
+	if err != nil {
+		return false, err
+	}
+
+	// Any subsequent apt_update will need to run again.
+	if changed || written {
+		system.Invalidate()
+	}
+
+	return changed || written, nil
+}
+
+// remove deletes the given files, reporting a change if any existed.
+func (a *AptRepoModule) remove(files []string) (bool, error) {
+
+	changed := false
+
+	for _, path := range files {
+		if !file.Exists(path) {
+			continue
+		}
+
+		log.Printf("[DEBUG] Removing %s", path)
+
+		err := os.Remove(path)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("apt_repository", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &AptRepoModule{
+			cfg:      cfg,
+			env:      env,
+			sources:  "/etc/apt/sources.list.d",
+			keyrings: "/etc/apt/keyrings",
+		}
+	})
+}
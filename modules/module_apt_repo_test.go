@@ -0,0 +1,175 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/marionette/file"
+)
+
+func TestAptRepoCheck(t *testing.T) {
+
+	a := &AptRepoModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'name'
+	err := a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing name")
+	}
+	if !strings.Contains(err.Error(), "missing 'name'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'uri'
+	args["name"] = "docker"
+	err = a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing uri")
+	}
+	if !strings.Contains(err.Error(), "missing 'uri'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'suite'
+	args["uri"] = "https://download.docker.com/linux/debian"
+	err = a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing suite")
+	}
+	if !strings.Contains(err.Error(), "missing 'suite'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	args["suite"] = "bookworm"
+	err = a.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus name
+	args["name"] = "../../passwd"
+	err = a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus name")
+	}
+
+	// Bogus state
+	args["name"] = "docker"
+	args["state"] = "bogus"
+	err = a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+
+	// Removal only needs a name
+	args = make(map[string]interface{})
+	args["name"] = "docker"
+	args["state"] = "absent"
+	err = a.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestAptRepoExecute(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "-----BEGIN PGP PUBLIC KEY BLOCK-----")
+	}))
+	defer ts.Close()
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	a := &AptRepoModule{
+		sources:  filepath.Join(dir, "sources.list.d"),
+		keyrings: filepath.Join(dir, "keyrings"),
+	}
+	err = os.MkdirAll(a.sources, 0755)
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+
+	list := filepath.Join(a.sources, "example.list")
+	key := filepath.Join(a.keyrings, "example.asc")
+
+	args := make(map[string]interface{})
+	args["name"] = "example"
+	args["uri"] = "https://example.com/debian"
+	args["suite"] = "bookworm"
+	args["components"] = []string{"main", "contrib"}
+	args["key_url"] = ts.URL
+
+	// First run creates the files
+	changed, err := a.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	data, err := ioutil.ReadFile(list)
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	expected := "deb [signed-by=" + key + "] https://example.com/debian bookworm main contrib\n"
+	if string(data) != expected {
+		t.Fatalf("unexpected content: %s", data)
+	}
+	if !file.Exists(key) {
+		t.Fatalf("signing key wasn't written")
+	}
+
+	// Second run changes nothing
+	changed, err = a.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change")
+	}
+
+	// A different suite is a change
+	args["suite"] = "trixie"
+	changed, err = a.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// Removal
+	args["state"] = "absent"
+	changed, err = a.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+	if file.Exists(list) || file.Exists(key) {
+		t.Fatalf("files weren't removed")
+	}
+
+	changed, err = a.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change")
+	}
+}
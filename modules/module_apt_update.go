@@ -0,0 +1,55 @@
+// This module updates the package-lists, at most once per run.
+
+package modules
+
+import (
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/modules/system"
+)
+
+// AptUpdateModule stores our state
+type AptUpdateModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (au *AptUpdateModule) Check(args map[string]interface{}) error {
+
+	// We have no required arguments.
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+//
+// The update is only carried out the first time this module is executed,
+// subsequent executions are no-ops unless a repository has been changed
+// in the meantime.
+func (au *AptUpdateModule) Execute(args map[string]interface{}) (bool, error) {
+
+	// Package abstraction
+	pkg := system.New()
+
+	// Do we need to use doas/sudo?
+	privs := StringParam(args, "elevate")
+	if privs != "" {
+		pkg.UsePrivilegeHelper(privs)
+	}
+
+	return pkg.UpdateOnce()
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("apt_update", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &AptUpdateModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+)
+
+// AssertModule stores our state.
+type AssertModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (a *AssertModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"that", "message"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (a *AssertModule) Execute(args map[string]interface{}) (bool, error) {
+
+	// The result of evaluating the expression, which is
+	// typically a function-call.
+	that := StringParam(args, "that")
+
+	// Is the result "truthy"?
+	//
+	// NOTE: This matches the handling of conditionals.
+	if that != "" && that != "false" && that != "0" {
+		return false, nil
+	}
+
+	// Get the message/messages to report.
+	strs := ArrayCastParam(args, "message")
+	if len(strs) < 1 {
+		return false, fmt.Errorf("missing 'message' parameter")
+	}
+
+	return false, fmt.Errorf("assertion failed: %s", strings.Join(strs, "\n"))
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("assert", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &AssertModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssertCheck(t *testing.T) {
+
+	a := &AssertModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'that'
+	err := a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing that")
+	}
+	if !strings.Contains(err.Error(), "missing 'that'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'message'
+	args["that"] = "true"
+	err = a.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing message")
+	}
+	if !strings.Contains(err.Error(), "missing 'message'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Valid
+	args["message"] = "config missing"
+	err = a.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestAssert(t *testing.T) {
+
+	a := &AssertModule{}
+
+	args := make(map[string]interface{})
+	args["message"] = "config missing"
+
+	// True values pass
+	for _, val := range []string{"true", "1", "yes"} {
+		args["that"] = val
+		changed, err := a.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", val, err)
+		}
+		if changed {
+			t.Fatalf("unexpected change")
+		}
+	}
+
+	// False values fail
+	for _, val := range []string{"false", "0", ""} {
+		args["that"] = val
+		changed, err := a.Execute(args)
+		if err == nil {
+			t.Fatalf("expected error for '%s', got none", val)
+		}
+		if !strings.Contains(err.Error(), "config missing") {
+			t.Fatalf("got error - but wrong one : %s", err)
+		}
+		if changed {
+			t.Fatalf("unexpected change")
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// BlockInFileModule stores our state.
+type BlockInFileModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (b *BlockInFileModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"target", "marker"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	switch state {
+	case "present":
+		_, ok := args["content"]
+		if !ok {
+			return fmt.Errorf("missing 'content' parameter")
+		}
+	case "absent":
+	default:
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (b *BlockInFileModule) Execute(args map[string]interface{}) (bool, error) {
+
+	target := StringParam(args, "target")
+	marker := StringParam(args, "marker")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	// The content may be a string, or an array of lines.
+	var content []string
+	if state == "present" {
+		for _, str := range ArrayCastParam(args, "content") {
+			content = append(content, strings.Split(strings.TrimSuffix(str, "\n"), "\n")...)
+		}
+	}
+
+	// Read the existing content, if the file exists.
+	var existing string
+	if file.Exists(target) {
+		data, err := ioutil.ReadFile(target)
+		if err != nil {
+			return false, err
+		}
+		existing = string(data)
+	} else if state == "absent" {
+		return false, nil
+	}
+
+	updated := updateBlock(existing, marker, content, state == "present")
+	if updated == existing {
+		return false, nil
+	}
+
+	err := ioutil.WriteFile(target, []byte(updated), 0644)
+	return err == nil, err
+}
+
+// updateBlock returns the given text with the block identified by the
+// marker replaced by the given lines.
+//
+// The block is delimited by "# BEGIN marionette $marker" and
+// "# END marionette $marker" comments.  If the block is missing it is
+// appended, and if present is false then it is removed entirely.
+func updateBlock(text string, marker string, lines []string, present bool) string {
+
+	begin := "# BEGIN marionette " + marker
+	end := "# END marionette " + marker
+
+	// Split into lines, ignoring the trailing newline.
+	var in []string
+	if text != "" {
+		in = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+
+	// The block we'll insert
+	var block []string
+	if present {
+		block = append(block, begin)
+		block = append(block, lines...)
+		block = append(block, end)
+	}
+
+	var out []string
+	found := false
+	inside := false
+
+	for _, line := range in {
+
+		if !inside && line == begin {
+			inside = true
+			found = true
+			out = append(out, block...)
+			continue
+		}
+		if inside {
+			if line == end {
+				inside = false
+			}
+			continue
+		}
+
+		out = append(out, line)
+	}
+
+	// Missing block?  Append it.
+	if !found {
+		out = append(out, block...)
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("blockinfile", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &BlockInFileModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
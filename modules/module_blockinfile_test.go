@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockInFileCheck(t *testing.T) {
+
+	b := &BlockInFileModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'target'
+	err := b.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing target")
+	}
+	if !strings.Contains(err.Error(), "missing 'target'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'marker'
+	args["target"] = "/tmp/hosts"
+	err = b.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing marker")
+	}
+	if !strings.Contains(err.Error(), "missing 'marker'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'content'
+	args["marker"] = "hosts"
+	err = b.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing content")
+	}
+	if !strings.Contains(err.Error(), "missing 'content'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// The content isn't required for removal
+	args["state"] = "absent"
+	err = b.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus state
+	args["state"] = "bogus"
+	err = b.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+}
+
+func TestBlockInFileExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "hosts")
+	err = ioutil.WriteFile(target, []byte("127.0.0.1 localhost\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	type TestCase struct {
+		Content interface{}
+		State   string
+		Changed bool
+		Output  string
+	}
+
+	tests := []TestCase{
+		// added to the end
+		{Content: "10.0.0.1 one\n10.0.0.2 two", Changed: true,
+			Output: "127.0.0.1 localhost\n# BEGIN marionette hosts\n10.0.0.1 one\n10.0.0.2 two\n# END marionette hosts\n"},
+
+		// unchanged
+		{Content: []string{"10.0.0.1 one", "10.0.0.2 two"}, Changed: false},
+
+		// updated in place
+		{Content: "10.0.0.3 three", Changed: true,
+			Output: "127.0.0.1 localhost\n# BEGIN marionette hosts\n10.0.0.3 three\n# END marionette hosts\n"},
+
+		// removed
+		{State: "absent", Changed: true,
+			Output: "127.0.0.1 localhost\n"},
+		{State: "absent", Changed: false},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["target"] = target
+		args["marker"] = "hosts"
+		args["state"] = test.State
+		if test.Content != nil {
+			args["content"] = test.Content
+		}
+
+		b := &BlockInFileModule{}
+		changed, err := b.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+
+		if test.Output != "" {
+			data, err := ioutil.ReadFile(target)
+			if err != nil {
+				t.Fatalf("failed to read file: %s", err)
+			}
+			if string(data) != test.Output {
+				t.Fatalf("unexpected output for %v:\n%s", test, data)
+			}
+		}
+	}
+}
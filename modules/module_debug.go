@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+)
+
+// DebugModule stores our state.
+type DebugModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (d *DebugModule) Check(args map[string]interface{}) error {
+
+	// All parameters are optional.
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (d *DebugModule) Execute(args map[string]interface{}) (bool, error) {
+
+	// Are we showing a single variable?
+	name := StringParam(args, "var")
+	if name != "" {
+		val, ok := d.env.Get(name)
+		if !ok {
+			return false, fmt.Errorf("variable '%s' is not set", name)
+		}
+		log.Printf("[INFO] %s => %s", name, val)
+		return false, nil
+	}
+
+	// Otherwise show all variables, sorted by name.
+	vars := d.env.Variables()
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		log.Printf("[INFO] %s => %s", key, vars[key])
+	}
+
+	return false, nil
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("debug", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &DebugModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
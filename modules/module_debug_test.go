@@ -0,0 +1,71 @@
+package modules
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/skx/marionette/environment"
+)
+
+func TestDebug(t *testing.T) {
+
+	// Save our log writer
+	before := log.Writer()
+	defer log.SetOutput(before)
+
+	// Change logger to write to a temporary buffer.
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	env := environment.New()
+	env.Set("ZEBRA", "stripes")
+	env.Set("APPLE", "red")
+
+	d := &DebugModule{env: env}
+	args := make(map[string]interface{})
+
+	err := d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Show everything
+	changed, err := d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change")
+	}
+
+	output := buf.String()
+	a := strings.Index(output, "APPLE => red")
+	z := strings.Index(output, "ZEBRA => stripes")
+	if a == -1 || z == -1 {
+		t.Fatalf("variables weren't shown: %s", output)
+	}
+	if a > z {
+		t.Fatalf("variables weren't sorted: %s", output)
+	}
+
+	// Show a single variable
+	buf.Reset()
+	args["var"] = "ZEBRA"
+	_, err = d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	output = buf.String()
+	if !strings.Contains(output, "ZEBRA => stripes") || strings.Contains(output, "APPLE") {
+		t.Fatalf("wrong output for single variable: %s", output)
+	}
+
+	// Missing variable
+	args["var"] = "MISSING"
+	_, err = d.Execute(args)
+	if err == nil {
+		t.Fatalf("expected error for missing variable")
+	}
+}
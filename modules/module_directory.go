@@ -2,8 +2,10 @@ package modules
 
 import (
 	"fmt"
+	"io/fs"
+	"log"
 	"os"
-	"strconv"
+	"path/filepath"
 
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -28,6 +30,15 @@ func (f *DirectoryModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("missing 'target' parameter")
 	}
 
+	// Purging without a list of the entries to keep would remove
+	// everything, so it must be given explicitly.
+	purge := StringParam(args, "purge")
+	if purge == "yes" || purge == "true" {
+		if _, ok := args["allowed"]; !ok {
+			return fmt.Errorf("'purge' requires the 'allowed' parameter")
+		}
+	}
+
 	// Target may be either a string or an array, so we don't test
 	// the type here.
 	return nil
@@ -102,14 +113,17 @@ func (f *DirectoryModule) executeSingle(target string, args map[string]interface
 		mode = "0755"
 	}
 
-	// Convert mode to int
-	modeI, _ := strconv.ParseInt(mode, 8, 64)
+	// Convert the mode, symbolic modes are relative to our default.
+	modeI, err := file.ParseMode(mode, os.ModeDir|0755)
+	if err != nil {
+		return false, err
+	}
 
 	// Create the directory, if it is missing, with the correct mode.
 	if !file.Exists(target) {
 
 		// make the directory hierarchy
-		er := os.MkdirAll(target, os.FileMode(modeI))
+		er := os.MkdirAll(target, modeI)
 		if er != nil {
 			return false, er
 		}
@@ -152,9 +166,137 @@ func (f *DirectoryModule) executeSingle(target string, args map[string]interface
 		changed = true
 	}
 
+	// Remove any unmanaged entries, if we should.
+	purge := StringParam(args, "purge")
+	if purge == "yes" || purge == "true" {
+		change, err = f.purge(target, ArrayCastParam(args, "allowed"))
+		if err != nil {
+			return false, err
+		}
+		if change {
+			changed = true
+		}
+	}
+
+	// Update the permissions of the contents, if we should.
+	//
+	// Only an explicit mode is applied to the children, our
+	// default shouldn't replace whatever modes they have.
+	recurse := StringParam(args, "recurse")
+	if recurse == "yes" || recurse == "true" {
+		change, err = f.applyRecursively(target, StringParam(args, "mode"), StringParam(args, "file_mode"), owner, group)
+		if err != nil {
+			return false, err
+		}
+		if change {
+			changed = true
+		}
+	}
+
 	return changed, nil
 }
 
+// applyRecursively walks the given directory, applying the mode to each
+// directory, and the fileMode to each regular file, beneath it.
+//
+// The owner and group are applied to every entry, if they're non-empty,
+// as is the fileMode.
+func (f *DirectoryModule) applyRecursively(target string, mode string, fileMode string, owner string, group string) (bool, error) {
+
+	changed := false
+
+	err := filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Don't follow, or modify, symlinks.
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		// Work out the mode to apply to this entry
+		m := fileMode
+		if d.IsDir() {
+			m = mode
+		}
+
+		if m != "" {
+			change, err := file.ChangeMode(path, m)
+			if err != nil {
+				return err
+			}
+			if change {
+				changed = true
+			}
+		}
+		if owner != "" {
+			change, err := file.ChangeOwner(path, owner)
+			if err != nil {
+				return err
+			}
+			if change {
+				changed = true
+			}
+		}
+		if group != "" {
+			change, err := file.ChangeGroup(path, group)
+			if err != nil {
+				return err
+			}
+			if change {
+				changed = true
+			}
+		}
+		return nil
+	})
+
+	return changed, err
+}
+
+// purge removes every entry within the given directory which is not
+// named in the list of allowed entries.
+func (f *DirectoryModule) purge(target string, allowed []string) (bool, error) {
+
+	changed := false
+
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+
+		// Is this entry allowed?
+		keep := false
+		for _, name := range allowed {
+			if entry.Name() == name {
+				keep = true
+			}
+		}
+		if keep {
+			continue
+		}
+
+		path := filepath.Join(target, entry.Name())
+		log.Printf("[DEBUG] Purging unmanaged entry %s", path)
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// KnownParams is an optional interface method which returns the names
+// of the parameters we understand.
+func (f *DirectoryModule) KnownParams() []string {
+	return []string{"allowed", "file_mode", "group", "mode", "owner", "purge", "recurse", "state", "target"}
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("directory", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
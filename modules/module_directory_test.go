@@ -171,3 +171,196 @@ func TestDirectoryMkdirP(t *testing.T) {
 	// cleanup
 	os.RemoveAll(dir)
 }
+
+func TestDirectoryPurge(t *testing.T) {
+
+	// Create a temporary directory
+	dir, err := os.MkdirTemp("", "m_d_t")
+	if err != nil {
+		t.Fatalf("failed to make temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	// Populate it with some files, and a directory.
+	for _, name := range []string{"keep.conf", "stray.conf"} {
+		err = os.WriteFile(filepath.Join(dir, name), []byte("test"), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	err = os.Mkdir(filepath.Join(dir, "stray.d"), 0755)
+	if err != nil {
+		t.Fatalf("failed to make directory: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dir
+	args["mode"] = "0700"
+	args["purge"] = "true"
+	args["allowed"] = []string{"keep.conf"}
+
+	d := &DirectoryModule{}
+	_, err = d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !file.Exists(filepath.Join(dir, "keep.conf")) {
+		t.Fatalf("allowed file was removed")
+	}
+	for _, name := range []string{"stray.conf", "stray.d"} {
+		if file.Exists(filepath.Join(dir, name)) {
+			t.Fatalf("unmanaged entry %s wasn't removed", name)
+		}
+	}
+
+	// Running again results in no change
+	changed, err := d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+}
+
+func TestDirectoryRecurse(t *testing.T) {
+
+	// Create a temporary directory
+	dir, err := os.MkdirTemp("", "m_d_t")
+	if err != nil {
+		t.Fatalf("failed to make temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "sub")
+	err = os.Mkdir(sub, 0755)
+	if err != nil {
+		t.Fatalf("failed to make directory: %s", err)
+	}
+	conf := filepath.Join(sub, "file.conf")
+	err = os.WriteFile(conf, []byte("test"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dir
+	args["mode"] = "0700"
+	args["file_mode"] = "0600"
+	args["recurse"] = "true"
+
+	d := &DirectoryModule{}
+	changed, err := d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// Test the modes
+	expected := map[string]os.FileMode{dir: 0700, sub: 0700, conf: 0600}
+	for path, mode := range expected {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %s", path, err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Fatalf("wrong mode for %s: %o", path, info.Mode().Perm())
+		}
+	}
+
+	// Running again results in no change
+	changed, err = d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+}
+
+// TestDirectoryRecurseDefaultMode ensures that the default mode isn't
+// applied to the children of a directory when recursing.
+func TestDirectoryRecurseDefaultMode(t *testing.T) {
+
+	// Create a temporary directory
+	dir, err := os.MkdirTemp("", "m_d_t")
+	if err != nil {
+		t.Fatalf("failed to make temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "sub")
+	err = os.Mkdir(sub, 0700)
+	if err != nil {
+		t.Fatalf("failed to make directory: %s", err)
+	}
+	err = os.Chmod(sub, 0700)
+	if err != nil {
+		t.Fatalf("failed to change mode: %s", err)
+	}
+	conf := filepath.Join(sub, "file.conf")
+	err = os.WriteFile(conf, []byte("test"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	// Recurse, without a mode
+	args := make(map[string]interface{})
+	args["target"] = dir
+	args["file_mode"] = "0600"
+	args["recurse"] = "true"
+
+	d := &DirectoryModule{}
+	_, err = d.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The target gets the default, the child keeps its mode
+	expected := map[string]os.FileMode{dir: 0755, sub: 0700, conf: 0600}
+	for path, mode := range expected {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %s", path, err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Fatalf("wrong mode for %s: %o", path, info.Mode().Perm())
+		}
+	}
+}
+
+// TestDirectoryPurgeAllowed ensures that purging requires the list of
+// entries to keep, so that a typo can't remove everything.
+func TestDirectoryPurgeAllowed(t *testing.T) {
+
+	d := &DirectoryModule{}
+
+	args := make(map[string]interface{})
+	args["target"] = "/tmp/foo"
+	args["purge"] = "true"
+
+	err := d.Check(args)
+	if err == nil {
+		t.Fatalf("expected an error purging without 'allowed'")
+	}
+	if !strings.Contains(err.Error(), "requires the 'allowed' parameter") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// A misspelled parameter doesn't count
+	args["allow"] = []string{"keep.conf"}
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected an error purging without 'allowed'")
+	}
+
+	// An explicitly empty list is fine
+	args["allowed"] = []string{}
+	err = d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -1,20 +1,42 @@
-// Allow fetching Docker images.
+// Allow fetching Docker images, and running containers.
 
 package modules
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
 )
 
+// The image-tags available on the local host are cached, and shared by
+// all docker rules, so that we only list the images once per run.
+var (
+	// dockerTagsMutex protects dockerTags.
+	dockerTagsMutex sync.Mutex
+
+	// dockerTags holds the cached tags, or nil if they've not been
+	// fetched since the last change.
+	dockerTags map[string]bool
+
+	// dockerImageTags returns the image-tags available on the local
+	// host.  It may be replaced for testing purposes.
+	dockerImageTags = listImageTags
+)
+
 // DockerModule stores our state
 type DockerModule struct {
 
@@ -24,8 +46,8 @@ type DockerModule struct {
 	// env holds our environment
 	env *environment.Environment
 
-	// Cached list of image-tags we've got available on the local host.
-	Tags []string
+	// auth holds the encoded registry credentials, if any.
+	auth string
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -37,70 +59,122 @@ func (dm *DockerModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("missing 'image' parameter")
 	}
 
-	return nil
+	// Credentials must be specified together.
+	user := StringParam(args, "username")
+	pass := StringParam(args, "password")
+	if (user == "") != (pass == "") {
+		return fmt.Errorf("'username' and 'password' must be specified together")
+	}
+	if user != "" && StringParam(args, "auth") != "" {
+		return fmt.Errorf("'auth' cannot be used with 'username' and 'password'")
+	}
+
+	// Ensure the state is valid.
+	state := StringParam(args, "state")
+	switch state {
+	case "", "present":
+	case "absent":
+		if StringParam(args, "action") == "run" {
+			return fmt.Errorf("state 'absent' cannot be used with action 'run'")
+		}
+	default:
+		return fmt.Errorf("unknown state '%s' - expected 'present' or 'absent'", state)
+	}
+
+	// Ensure the action is valid.
+	action := StringParam(args, "action")
+	switch action {
+	case "", "pull":
+		return nil
+	case "run":
+		// Running a container requires a name to find it by.
+		if StringParam(args, "name") == "" {
+			return fmt.Errorf("missing 'name' parameter")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown action '%s' - expected 'pull' or 'run'", action)
 }
 
 // isInstalled tests if the given image is installed
 func (dm *DockerModule) isInstalled(img string) (bool, error) {
 
-	//
-	// Cached tag-list already?
-	//
-	if len(dm.Tags) > 0 {
-
-		//
-		// Does the image appear in any of our cached tags?
-		//
-		for _, x := range dm.Tags {
-			if x == img {
-				return true, nil
-			}
+	dockerTagsMutex.Lock()
+	defer dockerTagsMutex.Unlock()
+
+	// Populate the cache, if we need to.
+	if dockerTags == nil {
+
+		tags, err := dockerImageTags()
+		if err != nil {
+			return false, err
 		}
 
-		//
-		// Not found.
-		//
-		return false, nil
+		dockerTags = make(map[string]bool)
+		for _, tag := range tags {
+			dockerTags[tag] = true
+		}
 	}
 
+	return dockerTags[img], nil
+}
+
+// invalidateTags discards the cached image-tags, which must be done
+// after images have been pulled or removed.
+func invalidateTags() {
+	dockerTagsMutex.Lock()
+	dockerTags = nil
+	dockerTagsMutex.Unlock()
+}
+
+// listImageTags returns the tags of all the images on the local host.
+func listImageTags() ([]string, error) {
+
 	// Create a new client.
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// Get all images
 	images, err := cli.ImageList(context.Background(), types.ImageListOptions{})
 	if err != nil {
-		return false, err
-
+		return nil, err
 	}
 
-	//
-	// If we reached here we have no cached tags.
-	//
-	// Save the tags in the cache before we look
-	// for a match.
-	//
-	found := false
+	var tags []string
 	for _, image := range images {
-		for _, x := range image.RepoTags {
+		tags = append(tags, image.RepoTags...)
+	}
 
-			// Update the cache
-			dm.Tags = append(dm.Tags, x)
-			if x == img {
-				found = true
-			}
-		}
+	return tags, nil
+}
+
+// registryAuth returns the encoded registry credentials, which are
+// specified either via "username" and "password", or via "auth".
+//
+// An empty string is returned if no credentials were supplied.
+func (dm *DockerModule) registryAuth(args map[string]interface{}) (string, error) {
+
+	cfg := types.AuthConfig{
+		Username: StringParam(args, "username"),
+		Password: StringParam(args, "password"),
+		Auth:     StringParam(args, "auth"),
+	}
+
+	if cfg.Username == "" && cfg.Auth == "" {
+		return "", nil
 	}
 
-	// Return the result
-	return found, nil
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
 }
 
 // installImage pulls the given image from the remote repository.
-//
-// NOTE: No authentication, or private registries are supported.
 func (dm *DockerModule) installImage(img string) error {
 
 	// Create client.
@@ -111,34 +185,216 @@ func (dm *DockerModule) installImage(img string) error {
 	}
 
 	// Pull the image.
-	out, err := cli.ImagePull(ctx, img, types.ImagePullOptions{})
+	out, err := cli.ImagePull(ctx, img, types.ImagePullOptions{RegistryAuth: dm.auth})
 	if err != nil {
 		return err
 	}
 
-	// Copy output to console.
-	//
-	// TODO: Clean this up
+	// The cached tags are now out of date.
+	defer invalidateTags()
+
 	defer out.Close()
 
-	if dm.cfg.Debug {
-		_, err := io.Copy(os.Stdout, out)
-		if err != nil {
-			return err
-		}
+	// Copy output to console, if we're debugging.
+	//
+	// NOTE: The pull isn't complete until the output has been read,
+	// so we must consume it even if we're not going to show it.
+	var dst io.Writer = ioutil.Discard
+	if dm.cfg != nil && dm.cfg.Debug {
+		dst = os.Stdout
+	}
+	_, err = io.Copy(dst, out)
+	if err != nil {
+		return err
 	}
 
 	// No error.
 	return nil
 }
 
+// removeImage removes the given image from the local host.
+func (dm *DockerModule) removeImage(img string) error {
+
+	// Create client.
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+
+	_, err = cli.ImageRemove(context.Background(), img, types.ImageRemoveOptions{PruneChildren: true})
+
+	// The cached tags are now out of date.
+	invalidateTags()
+
+	return err
+}
+
+// removeImages removes each of the given images which are present.
+func (dm *DockerModule) removeImages(images []string) (bool, error) {
+
+	removed := false
+
+	for _, img := range images {
+
+		// Check if it is installed
+		present, err := dm.isInstalled(img)
+		if err != nil {
+			return false, err
+		}
+		if !present {
+			continue
+		}
+
+		log.Printf("[INFO] Removing docker image %s\n", img)
+
+		err = dm.removeImage(img)
+		if err != nil {
+			return false, err
+		}
+		removed = true
+	}
+
+	return removed, nil
+}
+
+// findContainer returns the container with the given name, if it exists.
+func (dm *DockerModule) findContainer(cli *client.Client, name string) (*types.Container, error) {
+
+	// Find all containers, including stopped ones, matching the name.
+	//
+	// NOTE: The name-filter matches substrings, so we test for an
+	// exact match against the results.
+	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range containers {
+		for _, n := range c.Names {
+			if n == "/"+name {
+				return &containers[i], nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// runContainer ensures that a container with the given name is running,
+// creating and starting it if necessary.
+func (dm *DockerModule) runContainer(args map[string]interface{}) (bool, error) {
+
+	img := StringParam(args, "image")
+	if img == "" {
+		return false, fmt.Errorf("'image' must be a single string when running a container")
+	}
+	name := StringParam(args, "name")
+
+	// Create client.
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return false, err
+	}
+
+	// Does the container exist already?
+	existing, err := dm.findContainer(cli, name)
+	if err != nil {
+		return false, err
+	}
+
+	if existing != nil {
+
+		// Running already?  Then there is nothing to do.
+		if existing.State == "running" {
+			return false, nil
+		}
+
+		log.Printf("[INFO] Starting docker container %s\n", name)
+
+		err = cli.ContainerStart(ctx, existing.ID, types.ContainerStartOptions{})
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	// Ensure the image is available before we create the container.
+	present, err := dm.isInstalled(img)
+	if err != nil {
+		return false, err
+	}
+	if !present {
+		log.Printf("[INFO] Pulling docker image %s\n", img)
+
+		err = dm.installImage(img)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Parse any port-mappings, of the form "8080:80".
+	exposed, bindings, err := nat.ParsePortSpecs(ArrayCastParam(args, "ports"))
+	if err != nil {
+		return false, err
+	}
+
+	cfg := &container.Config{
+		Image:        img,
+		Env:          ArrayCastParam(args, "env"),
+		ExposedPorts: exposed,
+	}
+
+	host := &container.HostConfig{
+		Binds:        ArrayCastParam(args, "volumes"),
+		PortBindings: bindings,
+		RestartPolicy: container.RestartPolicy{
+			Name: StringParam(args, "restart"),
+		},
+	}
+
+	log.Printf("[INFO] Creating docker container %s from image %s\n", name, img)
+
+	created, err := cli.ContainerCreate(ctx, cfg, host, nil, nil, name)
+	if err != nil {
+		return false, err
+	}
+
+	err = cli.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 // Execute is part of the module-api, and is invoked to run a rule.
 func (dm *DockerModule) Execute(args map[string]interface{}) (bool, error) {
 
+	// Setup any registry credentials.
+	auth, err := dm.registryAuth(args)
+	if err != nil {
+		return false, err
+	}
+	dm.auth = auth
+
+	// Are we running a container?
+	if StringParam(args, "action") == "run" {
+		return dm.runContainer(args)
+	}
+
 	// No need to check if we have images as this was already done
 	// in Check()
 	images := ArrayCastParam(args, "image")
 
+	// Are we removing the images?
+	if StringParam(args, "state") == "absent" {
+		return dm.removeImages(images)
+	}
+
 	// Force the pull?
 	force := StringParam(args, "force")
 
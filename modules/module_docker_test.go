@@ -0,0 +1,184 @@
+package modules
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestDockerCheck(t *testing.T) {
+
+	d := &DockerModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'image'
+	err := d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing image")
+	}
+	if !strings.Contains(err.Error(), "missing 'image'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Default action is fine
+	args["image"] = "alpine:latest"
+	err = d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Running a container requires a name
+	args["action"] = "run"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing name")
+	}
+	if !strings.Contains(err.Error(), "missing 'name'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	args["name"] = "web"
+	err = d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Running containers cannot be combined with removal
+	args["state"] = "absent"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to state with action")
+	}
+
+	// Bogus state
+	args["action"] = "pull"
+	args["state"] = "bogus"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+
+	// Removal is fine
+	args["state"] = "absent"
+	err = d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus action
+	args["action"] = "bogus"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus action")
+	}
+}
+
+func TestDockerAuth(t *testing.T) {
+
+	d := &DockerModule{}
+
+	args := make(map[string]interface{})
+	args["image"] = "alpine:latest"
+
+	// No credentials
+	auth, err := d.registryAuth(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth != "" {
+		t.Fatalf("expected no auth, got %s", auth)
+	}
+
+	// Username without password
+	args["username"] = "steve"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing password")
+	}
+
+	args["password"] = "secret"
+	err = d.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	auth, err = d.registryAuth(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := base64.URLEncoding.DecodeString(auth)
+	if err != nil {
+		t.Fatalf("failed to decode auth: %s", err)
+	}
+
+	var cfg types.AuthConfig
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse auth: %s", err)
+	}
+	if cfg.Username != "steve" || cfg.Password != "secret" {
+		t.Fatalf("wrong credentials: %v", cfg)
+	}
+
+	// Both styles at once
+	args["auth"] = "c3RldmU6c2VjcmV0"
+	err = d.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to conflicting credentials")
+	}
+}
+
+func TestDockerTagCache(t *testing.T) {
+
+	// Count how often we list the images
+	calls := 0
+	dockerImageTags = func() ([]string, error) {
+		calls++
+		return []string{"alpine:latest", "debian:stable"}, nil
+	}
+	defer func() {
+		dockerImageTags = listImageTags
+		invalidateTags()
+	}()
+	invalidateTags()
+
+	// Separate instances share the cache
+	for _, img := range []string{"alpine:latest", "debian:stable"} {
+		d := &DockerModule{}
+		present, err := d.isInstalled(img)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !present {
+			t.Fatalf("expected %s to be present", img)
+		}
+	}
+
+	d := &DockerModule{}
+	present, err := d.isInstalled("ubuntu:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if present {
+		t.Fatalf("didn't expect image to be present")
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected a single listing, got %d", calls)
+	}
+
+	// Invalidating the cache causes a fresh listing
+	invalidateTags()
+	_, err = d.isInstalled("alpine:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a second listing, got %d", calls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"regexp"
 
@@ -20,6 +21,14 @@ type EditModule struct {
 
 	// env holds our environment
 	env *environment.Environment
+
+	// backup is true if we should backup the target before
+	// changing its contents.
+	backup bool
+
+	// literal is true if search & replace operations should
+	// treat their arguments literally, rather than as a regexp.
+	literal bool
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -36,6 +45,16 @@ func (e *EditModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("failed to convert target to string")
 	}
 
+	// Inserting a line requires the line, and a single location.
+	after := StringParam(args, "insert_after")
+	before := StringParam(args, "insert_before")
+	if after != "" && before != "" {
+		return fmt.Errorf("only one of 'insert_after' or 'insert_before' may be used")
+	}
+	if (after != "" || before != "") && StringParam(args, "line") == "" {
+		return fmt.Errorf("missing 'line' parameter")
+	}
+
 	return nil
 }
 
@@ -50,6 +69,14 @@ func (e *EditModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, fmt.Errorf("failed to convert target to string")
 	}
 
+	// Should we backup the target before changing it?
+	backup := StringParam(args, "backup")
+	e.backup = (backup == "yes" || backup == "true")
+
+	// Should searches be literal?
+	literal := StringParam(args, "literal")
+	e.literal = (literal == "yes" || literal == "true")
+
 	//
 	// Now look at our actions
 	//
@@ -79,11 +106,38 @@ func (e *EditModule) Execute(args map[string]interface{}) (bool, error) {
 		}
 	}
 
+	// Insert a line relative to another.
+	line := StringParam(args, "line")
+	fallback := StringParam(args, "fallback")
+	for _, where := range []string{"insert_after", "insert_before"} {
+		pattern := StringParam(args, where)
+		if pattern == "" || line == "" {
+			continue
+		}
+
+		changed, err := e.InsertLine(target, pattern, line, where == "insert_after", (fallback == "yes" || fallback == "true"))
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			ret = true
+		}
+	}
+
 	// Search & replace.
 	search := StringParam(args, "search")
 	replace := StringParam(args, "replace")
+	block := StringParam(args, "block")
 	if search != "" && replace != "" {
-		changed, err := e.SearchReplace(target, search, replace)
+
+		var changed bool
+		var err error
+
+		if block == "yes" || block == "true" {
+			changed, err = e.BlockReplace(target, search, replace)
+		} else {
+			changed, err = e.SearchReplace(target, search, replace)
+		}
 		if err != nil {
 			return false, err
 		}
@@ -142,6 +196,11 @@ func (e *EditModule) Append(path string, text string) (bool, error) {
 	}
 
 	// Otherwise we need to append the text
+	err = e.backupFile(path)
+	if err != nil {
+		return false, err
+	}
+
 	f, err := os.OpenFile(path,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -155,6 +214,103 @@ func (e *EditModule) Append(path string, text string) (bool, error) {
 	return true, nil
 }
 
+// InsertLine inserts the given line immediately after, or before, the
+// first line of the file which matches the given regular expression.
+//
+// If the line is already present nothing happens.  If no line matches
+// then the line is appended to the file, but only if fallback is set.
+func (e *EditModule) InsertLine(path string, pattern string, text string, after bool, fallback bool) (bool, error) {
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	// If the target file doesn't exist there is nothing to match.
+	if !file.Exists(path) {
+		if !fallback {
+			return false, nil
+		}
+		return e.Append(path, text)
+	}
+
+	// Open the input file
+	in, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	// Read the lines, finding the first match.
+	var lines []string
+	match := -1
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Already present?  Then we're done.
+		if line == text {
+			return false, nil
+		}
+
+		if match == -1 && re.MatchString(line) {
+			match = len(lines)
+		}
+		lines = append(lines, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return false, err
+	}
+
+	// No match?  Then append, if we should.
+	if match == -1 {
+		if !fallback {
+			return false, nil
+		}
+		return e.Append(path, text)
+	}
+
+	if after {
+		match++
+	}
+
+	// Open a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	// Write the lines, along with the new one.
+	for i, line := range lines {
+		if i == match {
+			_, err = tmpfile.WriteString(text + "\n")
+			if err != nil {
+				return false, err
+			}
+		}
+		_, err = tmpfile.WriteString(line + "\n")
+		if err != nil {
+			return false, err
+		}
+	}
+	if match == len(lines) {
+		_, err = tmpfile.WriteString(text + "\n")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = e.backupFile(path)
+	if err != nil {
+		return false, err
+	}
+	err = file.Copy(tmpfile.Name(), path)
+	return true, err
+}
+
 // RemoveLines remove any lines from the file which match the given
 // regular expression.
 func (e *EditModule) RemoveLines(path string, pattern string) (bool, error) {
@@ -210,6 +366,10 @@ func (e *EditModule) RemoveLines(path string, pattern string) (bool, error) {
 	}
 
 	// otherwise change
+	err = e.backupFile(path)
+	if err != nil {
+		return false, err
+	}
 	err = file.Copy(tmpfile.Name(), path)
 	return true, err
 }
@@ -217,7 +377,8 @@ func (e *EditModule) RemoveLines(path string, pattern string) (bool, error) {
 // SearchReplace performs a search and replace operation across all lines
 // of the given file.
 //
-// Searches are literal, rather than regexp.
+// Searches are regular expressions, so the replacement may refer to
+// capture groups via "$1", etc, unless the literal flag was set.
 func (e *EditModule) SearchReplace(path string, search string, replace string) (bool, error) {
 
 	// If the target file doesn't exist then we cannot change it.
@@ -225,6 +386,11 @@ func (e *EditModule) SearchReplace(path string, search string, replace string) (
 		return false, nil
 	}
 
+	// Literal searches have any special characters escaped.
+	if e.literal {
+		search = regexp.QuoteMeta(search)
+	}
+
 	// Compile the regular expression
 	term, errRE := regexp.Compile(search)
 	if errRE != nil {
@@ -253,7 +419,11 @@ func (e *EditModule) SearchReplace(path string, search string, replace string) (
 		line := scanner.Text()
 
 		// Perform any search-replace operation within the line
-		line = term.ReplaceAllString(line, replace)
+		if e.literal {
+			line = term.ReplaceAllLiteralString(line, replace)
+		} else {
+			line = term.ReplaceAllString(line, replace)
+		}
 
 		// Write the (updated) line to the temporary file
 		_, er := tmpfile.WriteString(line + "\n")
@@ -274,10 +444,99 @@ func (e *EditModule) SearchReplace(path string, search string, replace string) (
 	}
 
 	// otherwise change
+	err = e.backupFile(path)
+	if err != nil {
+		return false, err
+	}
+	err = file.Copy(tmpfile.Name(), path)
+	return true, err
+}
+
+// BlockReplace performs a search and replace operation across the whole
+// of the given file, rather than line by line, so that the search may
+// match text spanning several lines.
+//
+// The search is compiled with the "s" flag, so "." matches newlines.
+func (e *EditModule) BlockReplace(path string, search string, replace string) (bool, error) {
+
+	// If the target file doesn't exist then we cannot change it.
+	if !file.Exists(path) {
+		return false, nil
+	}
+
+	// Literal searches have any special characters escaped.
+	if e.literal {
+		search = regexp.QuoteMeta(search)
+	}
+
+	// Compile the regular expression
+	term, errRE := regexp.Compile("(?s)" + search)
+	if errRE != nil {
+		return false, errRE
+	}
+
+	// Read the input file
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Perform the replacement
+	var out string
+	if e.literal {
+		out = term.ReplaceAllLiteralString(string(data), replace)
+	} else {
+		out = term.ReplaceAllString(string(data), replace)
+	}
+
+	// Write the result to a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	_, err = tmpfile.WriteString(out)
+	if err != nil {
+		return false, err
+	}
+
+	// Now see if the content we wrote differs from the
+	// original input so we can signal a change, or not.
+	identical, err := file.Identical(tmpfile.Name(), path)
+	if err != nil {
+		return false, err
+	}
+
+	if identical {
+		return false, nil
+	}
+
+	// otherwise change
+	err = e.backupFile(path)
+	if err != nil {
+		return false, err
+	}
 	err = file.Copy(tmpfile.Name(), path)
 	return true, err
 }
 
+// backupFile takes a backup of the given file, if backups were requested.
+func (e *EditModule) backupFile(path string) error {
+
+	if !e.backup {
+		return nil
+	}
+
+	bak, err := file.Backup(path)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Backed up %s to %s", path, bak)
+	return nil
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("edit", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
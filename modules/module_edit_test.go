@@ -40,6 +40,30 @@ func TestEditCheck(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error")
 	}
+
+	// Inserting requires a line
+	args["insert_after"] = "^foo"
+	err = e.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing line")
+	}
+	if !strings.Contains(err.Error(), "missing 'line'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// But only in one place
+	args["line"] = "bar"
+	args["insert_before"] = "^foo"
+	err = e.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to two locations")
+	}
+
+	delete(args, "insert_before")
+	err = e.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
 }
 
 func TestEditAppend(t *testing.T) {
@@ -186,3 +210,207 @@ func TestEditRemove(t *testing.T) {
 	}
 
 }
+
+func TestEditSearchReplace(t *testing.T) {
+
+	// create a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	input := "listen 127.0.0.1\nlisten 127x0x0x1\nport=80\n"
+
+	type TestCase struct {
+		Search  string
+		Replace string
+		Literal string
+		Output  string
+	}
+
+	tests := []TestCase{
+		// By default "." matches anything
+		{Search: "127.0.0.1", Replace: "0.0.0.0",
+			Output: "listen 0.0.0.0\nlisten 0.0.0.0\nport=80\n"},
+
+		// Unless we're literal
+		{Search: "127.0.0.1", Replace: "0.0.0.0", Literal: "true",
+			Output: "listen 0.0.0.0\nlisten 127x0x0x1\nport=80\n"},
+
+		// Capture groups work with regexps
+		{Search: "^port=([0-9]+)$", Replace: "port = $1",
+			Output: "listen 127.0.0.1\nlisten 127x0x0x1\nport = 80\n"},
+
+		// But are literal otherwise
+		{Search: "port=80", Replace: "port=$1", Literal: "true",
+			Output: "listen 127.0.0.1\nlisten 127x0x0x1\nport=$1\n"},
+	}
+
+	for _, test := range tests {
+
+		err = ioutil.WriteFile(tmpfile.Name(), []byte(input), 0644)
+		if err != nil {
+			t.Fatalf("error writing temporary file")
+		}
+
+		args := make(map[string]interface{})
+		args["target"] = tmpfile.Name()
+		args["search"] = test.Search
+		args["replace"] = test.Replace
+		args["literal"] = test.Literal
+
+		e := &EditModule{}
+		changed, err := e.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !changed {
+			t.Fatalf("expected change, but got none")
+		}
+
+		data, err := ioutil.ReadFile(tmpfile.Name())
+		if err != nil {
+			t.Fatalf("failed to read file: %s", err)
+		}
+		if string(data) != test.Output {
+			t.Fatalf("unexpected output for %v:\n%s", test, data)
+		}
+	}
+}
+
+func TestEditInsert(t *testing.T) {
+
+	// create a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	input := "[main]\nfoo=1\n[other]\nbar=2\n"
+
+	type TestCase struct {
+		Where    string
+		Pattern  string
+		Fallback string
+		Changed  bool
+		Output   string
+	}
+
+	tests := []TestCase{
+		{Where: "insert_after", Pattern: "^\\[main\\]", Changed: true,
+			Output: "[main]\nnew=3\nfoo=1\n[other]\nbar=2\n"},
+		{Where: "insert_before", Pattern: "^\\[other\\]", Changed: true,
+			Output: "[main]\nfoo=1\nnew=3\n[other]\nbar=2\n"},
+		{Where: "insert_after", Pattern: "^bar", Changed: true,
+			Output: "[main]\nfoo=1\n[other]\nbar=2\nnew=3\n"},
+
+		// No match, and no fallback
+		{Where: "insert_after", Pattern: "^missing", Changed: false,
+			Output: input},
+
+		// No match, with a fallback
+		{Where: "insert_after", Pattern: "^missing", Fallback: "true", Changed: true,
+			Output: input + "\nnew=3"},
+	}
+
+	for _, test := range tests {
+
+		err = ioutil.WriteFile(tmpfile.Name(), []byte(input), 0644)
+		if err != nil {
+			t.Fatalf("error writing temporary file")
+		}
+
+		args := make(map[string]interface{})
+		args["target"] = tmpfile.Name()
+		args[test.Where] = test.Pattern
+		args["line"] = "new=3"
+		args["fallback"] = test.Fallback
+
+		e := &EditModule{}
+		changed, err := e.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+
+		data, err := ioutil.ReadFile(tmpfile.Name())
+		if err != nil {
+			t.Fatalf("failed to read file: %s", err)
+		}
+		if string(data) != test.Output {
+			t.Fatalf("unexpected output for %v:\n%s", test, data)
+		}
+
+		// Running again is never a change
+		changed, err = e.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed {
+			t.Fatalf("unexpected change on second run for %v", test)
+		}
+	}
+}
+
+func TestEditBlockReplace(t *testing.T) {
+
+	// create a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	err = ioutil.WriteFile(tmpfile.Name(), []byte("keep\n# BEGIN\nold one\nold two\n# END\nkeep\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing temporary file")
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = tmpfile.Name()
+	args["search"] = "# BEGIN\n.*# END\n"
+	args["replace"] = "# BEGIN\nnew\n# END\n"
+	args["block"] = "true"
+
+	e := &EditModule{}
+	changed, err := e.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected change, but got none")
+	}
+
+	data, err := ioutil.ReadFile(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	if string(data) != "keep\n# BEGIN\nnew\n# END\nkeep\n" {
+		t.Fatalf("unexpected output:\n%s", data)
+	}
+
+	// Second time nothing should happen
+	changed, err = e.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change, nothing should happen")
+	}
+
+	// Without block-mode nothing matches
+	args["search"] = "# BEGIN\n.*# END\n"
+	args["replace"] = "gone"
+	args["block"] = "false"
+	changed, err = e.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("unexpected change when not in block mode")
+	}
+}
@@ -3,6 +3,7 @@ package modules
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -42,14 +43,12 @@ func (f *FailModule) Execute(args map[string]interface{}) (bool, error) {
 	}
 
 	// process each argument
-	complete := ""
 	for _, str := range strs {
 		fmt.Fprintf(os.Stderr, "FAIL: %s\n", str)
-		complete += str + "\n"
 	}
 
 	// Return the joined error-message
-	return false, fmt.Errorf("%s", complete)
+	return false, fmt.Errorf("%s", strings.Join(strs, "\n"))
 
 }
 
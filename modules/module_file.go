@@ -1,14 +1,22 @@
 package modules
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"text/template"
+	"time"
 
+	"github.com/google/shlex"
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
 	"github.com/skx/marionette/file"
@@ -22,6 +30,21 @@ type FileModule struct {
 
 	// env contains the environment.
 	env *environment.Environment
+
+	// backup is true if we should backup the target before
+	// changing its contents.
+	backup bool
+
+	// validate holds a command which will be used to validate
+	// new content, before it replaces the target.
+	validate string
+
+	// target holds the path we operated upon, for our outputs.
+	target string
+
+	// mode holds the mode which was requested, if any, which new
+	// content is written with.
+	mode string
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -49,6 +72,17 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 
 	// Get the target (i.e. file/directory we're operating upon.)
 	target := StringParam(args, "target")
+	f.target = target
+
+	// Get the mode we should write new content with.
+	f.mode = StringParam(args, "mode")
+
+	// Should we backup the target before changing it?
+	backup := StringParam(args, "backup")
+	f.backup = (backup == "yes" || backup == "true")
+
+	// Should we validate new content before using it?
+	f.validate = StringParam(args, "validate")
 
 	// Get the directory-name
 	dir := filepath.Dir(target)
@@ -67,7 +101,45 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 	//
 	// Remove the file/directory, if we should.
 	if state == "absent" {
-		return f.removeFile(target)
+		recursive := StringParam(args, "recursive")
+		return f.removeFile(target, recursive == "yes" || recursive == "true")
+	}
+
+	// Create the file, or update its timestamps, if we should.
+	if state == "touch" {
+		ret, err = f.touchFile(target, args)
+		if err != nil {
+			return false, err
+		}
+
+		changed, err := f.applyPermissions(target, args)
+		if err != nil {
+			return false, err
+		}
+		return ret || changed, nil
+	}
+
+	// If we're operating recursively upon a directory then there
+	// is no content to populate, we just update the permissions
+	// of every entry beneath it.
+	recurse := StringParam(args, "recurse")
+	if recurse == "yes" || recurse == "true" {
+
+		info, err := os.Stat(target)
+		if err == nil && info.IsDir() {
+			return f.applyPermissionsRecursively(target, args)
+		}
+	}
+
+	// If the source is a glob, and the target is a directory, then
+	// we copy each matching file into it.
+	source := StringParam(args, "source")
+	if strings.ContainsAny(source, "*?[") {
+
+		info, err := os.Stat(target)
+		if err == nil && info.IsDir() {
+			return f.copyGlob(source, target, args)
+		}
 	}
 
 	//
@@ -82,11 +154,154 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 		return ret, err
 	}
 
+	// If the target was changed by anything other than a fetch then
+	// the details of any previous fetch are no longer valid.
+	if ret && StringParam(args, "source_url") == "" {
+		os.Remove(fetchSidecar(target))
+	}
+
+	// Update the owner/group/mode, if required.
+	changed, err := f.applyPermissions(target, args)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		ret = true
+	}
+
+	return ret, err
+}
+
+// copyGlob copies each file which matches the given pattern into the
+// target directory, and updates their owner/group/mode, if required.
+//
+// If any single file is changed then we report a change.
+func (f *FileModule) copyGlob(pattern string, target string, args map[string]interface{}) (bool, error) {
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	ret := false
+	copied := 0
+
+	for _, src := range matches {
+
+		// Directories are not copied.
+		info, err := os.Stat(src)
+		if err != nil {
+			return false, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		dst := filepath.Join(target, filepath.Base(src))
+
+		changed, err := f.CopyFile(src, dst)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			ret = true
+		}
+
+		changed, err = f.applyPermissions(dst, args)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			ret = true
+		}
+		copied++
+	}
+
+	if copied == 0 {
+		return false, fmt.Errorf("no files matched %s", pattern)
+	}
+
+	return ret, nil
+}
+
+// touchFile creates the given file, empty, if it doesn't exist.  If it
+// does exist then its access and modification times are updated.
+//
+// We report a change if the file was created, or if the timestamps were
+// updated and "touch_changed" is set.
+func (f *FileModule) touchFile(target string, args map[string]interface{}) (bool, error) {
+
+	if !file.Exists(target) {
+		log.Printf("[INFO] Creating empty file %s\n", target)
+
+		// Create the file with the requested mode, if any.
+		mode := os.FileMode(0644)
+		if f.mode != "" {
+			var err error
+			mode, err = f.targetMode(target)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		fh, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, mode)
+		if err != nil {
+			return false, err
+		}
+		return true, fh.Close()
+	}
+
+	now := time.Now()
+	err := os.Chtimes(target, now, now)
+	if err != nil {
+		return false, err
+	}
+
+	changed := StringParam(args, "touch_changed")
+	return changed == "yes" || changed == "true", nil
+}
+
+// applyPermissionsRecursively walks the given directory, and applies
+// any requested mode/owner/group to each entry beneath it.
+//
+// If any single entry is changed then we report a change.
+func (f *FileModule) applyPermissionsRecursively(target string, args map[string]interface{}) (bool, error) {
+
+	ret := false
+
+	err := filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Don't follow, or modify, symlinks.
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		changed, err := f.applyPermissions(path, args)
+		if err != nil {
+			return err
+		}
+		if changed {
+			ret = true
+		}
+		return nil
+	})
+
+	return ret, err
+}
+
+// applyPermissions updates the mode, owner, group, and SELinux context
+// of the given path, if those parameters were specified.
+func (f *FileModule) applyPermissions(target string, args map[string]interface{}) (bool, error) {
+
+	ret := false
+
 	// File permission changes
 	mode := StringParam(args, "mode")
 	if mode != "" {
-		var changed bool
-		changed, err = file.ChangeMode(target, mode)
+		changed, err := file.ChangeMode(target, mode)
 		if err != nil {
 			return false, err
 		}
@@ -98,8 +313,7 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 	// User and group changes
 	owner := StringParam(args, "owner")
 	if owner != "" {
-		var changed bool
-		changed, err = file.ChangeOwner(target, owner)
+		changed, err := file.ChangeOwner(target, owner)
 		if err != nil {
 			return false, err
 		}
@@ -109,8 +323,7 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 	}
 	group := StringParam(args, "group")
 	if group != "" {
-		var changed bool
-		changed, err = file.ChangeGroup(target, group)
+		changed, err := file.ChangeGroup(target, group)
 		if err != nil {
 			return false, err
 		}
@@ -119,21 +332,63 @@ func (f *FileModule) Execute(args map[string]interface{}) (bool, error) {
 		}
 	}
 
-	return ret, err
+	// SELinux context changes
+	context := StringParam(args, "selinux_context")
+	if context != "" {
+		changed, err := file.ChangeContext(target, context)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			ret = true
+		}
+	}
+
+	return ret, nil
 }
 
 // removeFile removes the named file, returning whether a change
-// was made or not
-func (f *FileModule) removeFile(target string) (bool, error) {
+// was made or not.
+//
+// Symlinks are removed without touching the thing they point to,
+// and directories are only removed along with their contents if
+// we're running recursively.
+func (f *FileModule) removeFile(target string, recursive bool) (bool, error) {
 
 	// Does it exist?
-	if file.Exists(target) {
-		err := os.Remove(target)
-		return true, err
+	//
+	// NOTE: We use Lstat so that we find dangling symlinks.
+	info, err := os.Lstat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Didn't exist, nothing to change.
+			return false, nil
+		}
+		return false, err
+	}
+
+	// A symlink is removed, rather than the thing it points to.
+	if info.Mode()&os.ModeSymlink != 0 {
+		err = os.Remove(target)
+		return err == nil, err
+	}
+
+	// A directory will be removed with all of its contents.
+	if info.IsDir() && recursive {
+		err = os.RemoveAll(target)
+		return err == nil, err
 	}
 
-	// Didn't exist, nothing to change.
-	return false, nil
+	// Otherwise we just remove the single entry, which will
+	// fail for a non-empty directory.
+	err = os.Remove(target)
+	if err != nil {
+		return false, err
+	}
+
+	// Along with the details of any previous fetch.
+	os.Remove(fetchSidecar(target))
+	return true, nil
 }
 
 // populateFile is designed to create/update the file contents via one
@@ -160,7 +415,36 @@ func (f *FileModule) populateFile(target string, args map[string]interface{}) (b
 
 	// If we have a content to set, then use it.
 	content := StringParam(args, "content")
-	if content != "" {
+
+	// If we have the name of a variable then its raw value is the
+	// content to use - which might legitimately be empty.
+	name := StringParam(args, "content_var")
+	if name != "" {
+		val, ok := f.env.Get(name)
+		if !ok {
+			return false, fmt.Errorf("variable '%s' is not set", name)
+		}
+		content = val
+	}
+
+	if content != "" || name != "" {
+
+		// Are we appending, rather than replacing?
+		appendMode := StringParam(args, "append")
+		if appendMode == "yes" || appendMode == "true" {
+			ret, err = f.AppendFile(target, content)
+			return ret, err
+		}
+
+		// Are trailing newlines significant?
+		ignore := StringParam(args, "ignore_trailing_newline")
+		if ignore == "yes" || ignore == "true" {
+			same, err := sameTrimmedContent(target, content)
+			if err != nil || same {
+				return false, err
+			}
+		}
+
 		ret, err = f.CreateFile(target, content)
 		return ret, err
 	}
@@ -172,7 +456,15 @@ func (f *FileModule) populateFile(target string, args map[string]interface{}) (b
 		return ret, err
 	}
 
-	return ret, fmt.Errorf("neither 'content', 'source', 'source_url', or 'template' were specified")
+	// If we're only enforcing permissions then there is no
+	// content to populate, and that is fine.
+	for _, key := range []string{"mode", "owner", "group", "selinux_context"} {
+		if StringParam(args, key) != "" {
+			return false, nil
+		}
+	}
+
+	return ret, fmt.Errorf("neither 'content', 'content_var', 'source', 'source_url', or 'template' were specified")
 }
 
 // CopyFile copies the source file to the destination, returning if we changed
@@ -181,7 +473,11 @@ func (f *FileModule) CopyFile(src string, dst string) (bool, error) {
 
 	// File doesn't exist - copy it
 	if !file.Exists(dst) {
-		err := file.Copy(src, dst)
+		err := f.validateFile(src)
+		if err != nil {
+			return false, err
+		}
+		err = f.copy(src, dst)
 		return true, err
 	}
 
@@ -196,11 +492,92 @@ func (f *FileModule) CopyFile(src string, dst string) (bool, error) {
 		return false, err
 	}
 
+	// Ensure the new content is valid, before we replace anything.
+	err = f.validateFile(src)
+	if err != nil {
+		return false, err
+	}
+
+	// Backup the existing content, if we should.
+	if f.backup {
+		var bak string
+		bak, err = file.Backup(dst)
+		if err != nil {
+			return false, err
+		}
+		log.Printf("[DEBUG] Backed up %s to %s", dst, bak)
+	}
+
 	// Since they differ we refresh and that's a change
-	err = file.Copy(src, dst)
+	err = f.copy(src, dst)
 	return true, err
 }
 
+// copy copies the source file to the destination.
+//
+// If a mode was requested it is set before any content is written, so
+// that the content is never visible with the wrong permissions.
+func (f *FileModule) copy(src string, dst string) error {
+
+	if f.mode == "" {
+		return file.Copy(src, dst)
+	}
+
+	m, err := f.targetMode(dst)
+	if err != nil {
+		return err
+	}
+	return file.CopyMode(src, dst, m)
+}
+
+// targetMode returns the requested mode for the given path, which might
+// be relative to its current mode, or our default if it doesn't exist.
+func (f *FileModule) targetMode(path string) (os.FileMode, error) {
+
+	current := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		current = info.Mode()
+	}
+
+	return file.ParseMode(f.mode, current)
+}
+
+// validateFile runs the validation command, if one was specified, against
+// the given file.
+//
+// Any "%s" in the command is replaced with the path to the file, and
+// a non-zero exit-code is regarded as a validation failure.
+func (f *FileModule) validateFile(path string) error {
+
+	if f.validate == "" {
+		return nil
+	}
+
+	// Split the command into arguments, and insert the path
+	cmdArgs, err := shlex.Split(f.validate)
+	if err != nil {
+		return err
+	}
+	if len(cmdArgs) < 1 {
+		return fmt.Errorf("empty validation command")
+	}
+	for i, arg := range cmdArgs {
+		cmdArgs[i] = strings.ReplaceAll(arg, "%s", path)
+	}
+
+	// Show what we're doing
+	log.Printf("[DEBUG] Validating with %s", cmdArgs)
+
+	// Run it
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("validation command '%s' failed: %s %s", f.validate, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
 // CopyTemplateFile copies the template file to the destination, rendering the
 // template and returning if we changed the contents.
 func (f *FileModule) CopyTemplateFile(src string, dst string) (bool, error) {
@@ -229,6 +606,11 @@ func (f *FileModule) CopyTemplateFile(src string, dst string) (bool, error) {
 
 // FetchURL retrieves the contents of the remote URL and saves them to
 // the given file.  If the contents are identical no change is reported.
+//
+// The ETag and Last-Modified headers of the response are saved in a file
+// alongside the target, and if the target still holds the content we
+// fetched the next request is made conditional.  If the server reports
+// the content is unmodified we don't download it again.
 func (f *FileModule) FetchURL(url string, dst string) (bool, error) {
 
 	// Download to temporary file
@@ -238,20 +620,129 @@ func (f *FileModule) FetchURL(url string, dst string) (bool, error) {
 	}
 	defer os.Remove(tmpfile.Name())
 
+	// Create the request
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// If the target still holds the content we fetched previously we
+	// can make the request conditional.
+	if prev := readFetchDetails(dst); prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
 	// Get the remote URL
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false, err
 	}
 	defer resp.Body.Close()
 
+	// Not modified?  Then there's nothing to do.
+	if resp.StatusCode == http.StatusNotModified {
+		log.Printf("[DEBUG] %s not modified, skipping download", url)
+		return false, nil
+	}
+
+	// Any other failure is an error.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+
 	// Write the body to file
 	_, err = io.Copy(tmpfile, resp.Body)
 	if err != nil {
 		return false, err
 	}
 
-	return f.CopyFile(tmpfile.Name(), dst)
+	ret, err := f.CopyFile(tmpfile.Name(), dst)
+	if err != nil {
+		return ret, err
+	}
+
+	// Save the details of what we fetched, for the next run.
+	hash, err := file.HashFile(tmpfile.Name())
+	if err != nil {
+		return ret, err
+	}
+	err = writeFetchDetails(dst, fetchDetails{
+		SHA1:         hash,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+	return ret, err
+}
+
+// fetchDetails holds the details of the last fetch of a target.
+type fetchDetails struct {
+
+	// SHA1 is the hash of the content which was fetched.
+	SHA1 string `json:"sha1"`
+
+	// ETag is the ETag header of the response, if any.
+	ETag string `json:"etag,omitempty"`
+
+	// LastModified is the Last-Modified header of the response, if any.
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// fetchSidecar returns the name of the file which holds the details of
+// the last fetch of the given target.
+func fetchSidecar(dst string) string {
+	return dst + ".marionette-fetch"
+}
+
+// readFetchDetails returns the details of the last fetch of the given
+// target, if it still holds the content which was fetched.
+//
+// If the target has changed since then the details are removed, and
+// nil is returned.
+func readFetchDetails(dst string) *fetchDetails {
+
+	data, err := ioutil.ReadFile(fetchSidecar(dst))
+	if err != nil {
+		return nil
+	}
+
+	var prev fetchDetails
+	err = json.Unmarshal(data, &prev)
+	if err == nil {
+		current, hErr := file.HashFile(dst)
+		if hErr == nil && current == prev.SHA1 {
+			return &prev
+		}
+	}
+
+	log.Printf("[DEBUG] %s has changed since it was fetched", dst)
+	os.Remove(fetchSidecar(dst))
+	return nil
+}
+
+// writeFetchDetails saves the details of the fetch of the given target.
+//
+// If the server gave us nothing to make a conditional request with
+// then any previous details are removed instead.
+func writeFetchDetails(dst string, details fetchDetails) error {
+
+	if details.ETag == "" && details.LastModified == "" {
+		err := os.Remove(fetchSidecar(dst))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fetchSidecar(dst), data, 0644)
 }
 
 // CreateFile writes the given content to the named file.
@@ -274,6 +765,78 @@ func (f *FileModule) CreateFile(dst string, content string) (bool, error) {
 	return f.CopyFile(tmpfile.Name(), dst)
 }
 
+// sameTrimmedContent returns true if the given file exists, and has the
+// same content as that specified, once any trailing newlines are removed
+// from both.
+func sameTrimmedContent(path string, content string) (bool, error) {
+
+	if !file.Exists(path) {
+		return false, nil
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimRight(string(existing), "\r\n") == strings.TrimRight(content, "\r\n"), nil
+}
+
+// AppendFile appends the given content to the named file, unless the
+// file already ends with that content.
+//
+// If the file doesn't exist it will be created.
+func (f *FileModule) AppendFile(dst string, content string) (bool, error) {
+
+	// Read the existing content, if any.
+	existing := []byte{}
+	if file.Exists(dst) {
+		var err error
+		existing, err = ioutil.ReadFile(dst)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Already present at the end?  Then nothing to do.
+	if bytes.HasSuffix(existing, []byte(content)) {
+		return false, nil
+	}
+
+	// Otherwise create the updated content, and write it via
+	// the same path as our other content-changes.
+	return f.CreateFile(dst, string(existing)+content)
+}
+
+// GetOutputs is an optional interface method which allows the
+// module to return values to the caller - prefixed by the rule-name.
+func (f *FileModule) GetOutputs() map[string]string {
+
+	// Prepare a map of key->values to return
+	m := make(map[string]string)
+
+	m["path"] = f.target
+
+	// The hash is only available for a regular file, which might
+	// not exist if we've removed it.
+	m["sha1"] = ""
+	info, err := os.Stat(f.target)
+	if err == nil && info.Mode().IsRegular() {
+		hash, err := file.HashFile(f.target)
+		if err == nil {
+			m["sha1"] = hash
+		}
+	}
+
+	return m
+}
+
+// KnownParams is an optional interface method which returns the names
+// of the parameters we understand.
+func (f *FileModule) KnownParams() []string {
+	return []string{"append", "backup", "content", "content_var", "group", "ignore_trailing_newline", "mode", "owner", "recurse", "recursive", "selinux_context", "source", "source_url", "state", "target", "template", "touch_changed", "validate"}
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("file", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
@@ -1,11 +1,17 @@
 package modules
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/skx/marionette/environment"
 	"github.com/skx/marionette/file"
 )
 
@@ -89,3 +95,862 @@ func TestAbsent(t *testing.T) {
 		t.Fatalf("didn't expect a change, but got one")
 	}
 }
+
+func TestTouch(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "marker")
+
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["state"] = "touch"
+
+	// Creating the file is a change
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("file wasn't created: %s", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("file isn't empty")
+	}
+
+	// Set the times into the past, so we can see them updated.
+	old := time.Now().Add(-time.Hour)
+	err = os.Chtimes(target, old, old)
+	if err != nil {
+		t.Fatalf("failed to change times: %s", err)
+	}
+
+	// Touching an existing file is not a change, by default
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	info, err = os.Stat(target)
+	if err != nil {
+		t.Fatalf("failed to stat file: %s", err)
+	}
+	if !info.ModTime().After(old) {
+		t.Fatalf("modification time wasn't updated")
+	}
+
+	// Unless we ask for it to be
+	args["touch_changed"] = "yes"
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+}
+
+func TestSourceGlob(t *testing.T) {
+
+	src, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(src)
+
+	dst, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dst)
+
+	for name, content := range map[string]string{"a.conf": "one", "b.conf": "two", "c.txt": "three"} {
+		err = ioutil.WriteFile(filepath.Join(src, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dst
+	args["source"] = filepath.Join(src, "*.conf")
+
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	for name, content := range map[string]string{"a.conf": "one", "b.conf": "two"} {
+		data, err := ioutil.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("file %s wasn't copied: %s", name, err)
+		}
+		if string(data) != content {
+			t.Fatalf("wrong content for %s: %s", name, data)
+		}
+	}
+	if file.Exists(filepath.Join(dst, "c.txt")) {
+		t.Fatalf("unmatched file was copied")
+	}
+
+	// Copying again is not a change
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	// A pattern which matches nothing is an error
+	args["source"] = filepath.Join(src, "*.missing")
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected error with no matches")
+	}
+}
+
+func TestIgnoreTrailingNewline(t *testing.T) {
+
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	type TestCase struct {
+		Existing string
+		Content  string
+		Ignore   bool
+		Changed  bool
+	}
+
+	tests := []TestCase{
+		// Without normalization any difference is a change
+		{Existing: "hello\n", Content: "hello", Ignore: false, Changed: true},
+		{Existing: "hello", Content: "hello", Ignore: false, Changed: false},
+
+		// With normalization trailing newlines are ignored
+		{Existing: "hello\n", Content: "hello", Ignore: true, Changed: false},
+		{Existing: "hello", Content: "hello\n\n", Ignore: true, Changed: false},
+		{Existing: "hello\n", Content: "world", Ignore: true, Changed: true},
+		{Existing: "\nhello", Content: "hello", Ignore: true, Changed: true},
+	}
+
+	for _, test := range tests {
+
+		err = ioutil.WriteFile(tmpfile.Name(), []byte(test.Existing), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+
+		args := make(map[string]interface{})
+		args["target"] = tmpfile.Name()
+		args["content"] = test.Content
+		if test.Ignore {
+			args["ignore_trailing_newline"] = "true"
+		}
+
+		f := &FileModule{}
+		changed, err := f.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %q -> %q: %t", test.Existing, test.Content, changed)
+		}
+
+		// The file is left alone if there was no change
+		data, err := ioutil.ReadFile(tmpfile.Name())
+		if err != nil {
+			t.Fatalf("failed to read file: %s", err)
+		}
+		expected := test.Existing
+		if changed {
+			expected = test.Content
+		}
+		if string(data) != expected {
+			t.Fatalf("unexpected content: %q", data)
+		}
+	}
+}
+
+func TestRecurse(t *testing.T) {
+
+	// Create a temporary directory, with some children
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "sub")
+	err = os.Mkdir(sub, 0755)
+	if err != nil {
+		t.Fatalf("failed to create sub-directory: %s", err)
+	}
+
+	for _, name := range []string{filepath.Join(dir, "one"), filepath.Join(sub, "two")} {
+		err = ioutil.WriteFile(name, []byte("test"), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dir
+	args["recurse"] = "true"
+	args["mode"] = "0700"
+
+	// Run the module
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// Every entry should have the new mode
+	for _, name := range []string{dir, sub, filepath.Join(dir, "one"), filepath.Join(sub, "two")} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("failed to stat %s: %s", name, err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Fatalf("wrong mode for %s: %o", name, info.Mode().Perm())
+		}
+	}
+
+	// Running again results in no change
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+}
+
+// TestRecurseSymlinks ensures that symlinks are ignored when recursing,
+// so that their targets are left alone and dangling links are harmless.
+func TestRecurseSymlinks(t *testing.T) {
+
+	// Create a temporary directory to recurse within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// And a file outside it, which a link will point to
+	outside, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(outside.Name())
+	outside.Close()
+
+	err = os.Chmod(outside.Name(), 0644)
+	if err != nil {
+		t.Fatalf("failed to change mode: %s", err)
+	}
+
+	err = os.Symlink(outside.Name(), filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+	err = os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "dangling"))
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dir
+	args["recurse"] = "true"
+	args["mode"] = "0700"
+
+	f := &FileModule{}
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The target of the link should be unchanged
+	info, err := os.Stat(outside.Name())
+	if err != nil {
+		t.Fatalf("failed to stat %s: %s", outside.Name(), err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Fatalf("symlink target was changed: %o", info.Mode().Perm())
+	}
+
+	// The directory itself should have been updated
+	info, err = os.Stat(dir)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %s", dir, err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("wrong mode for %s: %o", dir, info.Mode().Perm())
+	}
+}
+
+func TestBackup(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target")
+
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content"] = "one"
+	args["backup"] = "true"
+
+	// Count the files in our directory
+	count := func() int {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("failed to read directory: %s", err)
+		}
+		return len(entries)
+	}
+
+	// Creating the file shouldn't result in a backup
+	f := &FileModule{}
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count() != 1 {
+		t.Fatalf("unexpected backup when creating the file")
+	}
+
+	// Nor should running with the same content
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count() != 1 {
+		t.Fatalf("unexpected backup when the file didn't change")
+	}
+
+	// But changing it should
+	args["content"] = "two"
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count() != 2 {
+		t.Fatalf("expected a backup to be made")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target")
+
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content"] = "valid content"
+	args["validate"] = "grep -q valid %s"
+
+	// Valid content is written
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// Content which fails validation is not
+	args["content"] = "broken"
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if !strings.Contains(err.Error(), "validation command") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %s", err)
+	}
+	if string(data) != "valid content" {
+		t.Fatalf("target was changed despite failing validation")
+	}
+}
+
+func TestAbsentRecursive(t *testing.T) {
+
+	// Create a temporary directory, with some content
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	tree := filepath.Join(dir, "tree")
+	err = os.MkdirAll(filepath.Join(tree, "sub"), 0755)
+	if err != nil {
+		t.Fatalf("failed to create directory tree: %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(tree, "sub", "file"), []byte("test"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = tree
+	args["state"] = "absent"
+
+	// Without recursion removing a non-empty directory fails
+	f := &FileModule{}
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error removing a non-empty directory")
+	}
+	if !file.Exists(tree) {
+		t.Fatalf("directory was removed, unexpectedly")
+	}
+
+	// "recurse" only applies to permissions, not removal
+	args["recurse"] = "true"
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error removing a non-empty directory")
+	}
+	if !file.Exists(tree) {
+		t.Fatalf("directory was removed, unexpectedly")
+	}
+	delete(args, "recurse")
+
+	// With recursion it succeeds
+	args["recursive"] = "true"
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+	if file.Exists(tree) {
+		t.Fatalf("directory still exists, but should have been removed")
+	}
+
+	// Running again is a no-op
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+}
+
+func TestAbsentSymlink(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// A dangling symlink
+	link := filepath.Join(dir, "dangling")
+	err = os.Symlink(filepath.Join(dir, "missing"), link)
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	// A symlink to a directory, which should survive
+	target := filepath.Join(dir, "target")
+	err = os.Mkdir(target, 0755)
+	if err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	link2 := filepath.Join(dir, "link")
+	err = os.Symlink(target, link2)
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	f := &FileModule{}
+
+	for _, path := range []string{link, link2} {
+
+		args := make(map[string]interface{})
+		args["target"] = path
+		args["state"] = "absent"
+		args["recursive"] = "true"
+
+		changed, err := f.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !changed {
+			t.Fatalf("expected a change removing %s", path)
+		}
+
+		_, err = os.Lstat(path)
+		if !os.IsNotExist(err) {
+			t.Fatalf("symlink %s still exists", path)
+		}
+	}
+
+	// The directory the link pointed to is still present
+	if !file.Exists(target) {
+		t.Fatalf("symlink target was removed")
+	}
+}
+
+func TestAppend(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target")
+	err = ioutil.WriteFile(target, []byte("one\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content"] = "two\n"
+	args["append"] = "true"
+
+	// The first run appends
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// The second run doesn't
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %s", err)
+	}
+	if string(data) != "one\ntwo\n" {
+		t.Fatalf("wrong content after appending: %q", data)
+	}
+}
+
+func TestContentVar(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// A multi-line value, containing things that look like variables.
+	value := "line one\n\tline \"two\" ${NOT_A_VAR}\n"
+
+	env := environment.New()
+	env.Set("captured", value)
+
+	target := filepath.Join(dir, "target")
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content_var"] = "captured"
+
+	f := &FileModule{env: env}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %s", err)
+	}
+	if string(data) != value {
+		t.Fatalf("wrong content: %q", data)
+	}
+
+	// A missing variable is an error
+	args["content_var"] = "missing"
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected error with a missing variable")
+	}
+}
+
+func TestFileOutputs(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target")
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content"] = "hello\n"
+
+	f := &FileModule{}
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	out := f.GetOutputs()
+	if out["path"] != target {
+		t.Fatalf("wrong path output: %s", out["path"])
+	}
+	if out["sha1"] != "f572d396fae9206628714fb2ce00f72e94f2258f" {
+		t.Fatalf("wrong sha1 output: %s", out["sha1"])
+	}
+
+	// Once removed there is no hash
+	args["state"] = "absent"
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	out = f.GetOutputs()
+	if out["sha1"] != "" {
+		t.Fatalf("unexpected sha1 output after removal: %s", out["sha1"])
+	}
+}
+
+func TestModeOnly(t *testing.T) {
+
+	// Create a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary file failed")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	err = os.Chmod(tmpfile.Name(), 0644)
+	if err != nil {
+		t.Fatalf("failed to chmod: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = tmpfile.Name()
+	args["mode"] = "0600"
+
+	// Run the module, with no content
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	info, err := os.Stat(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("failed to stat: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong mode: %o", info.Mode().Perm())
+	}
+
+	// Running again results in no change
+	changed, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	// With no mode, and no content, we get an error
+	delete(args, "mode")
+	_, err = f.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error with no content, got none")
+	}
+}
+
+func TestFetchConditional(t *testing.T) {
+
+	// Count the number of times we served the body.
+	served := 0
+
+	// A server which uses an ETag
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		served++
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "remote content")
+	}))
+	defer ts.Close()
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "target")
+
+	// Each fetch uses a new module, as if it were a separate run.
+	env := environment.New()
+	f := &FileModule{env: env}
+
+	// The first fetch downloads
+	changed, err := f.FetchURL(ts.URL, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// The second is not modified
+	f = &FileModule{env: environment.New()}
+	changed, err = f.FetchURL(ts.URL, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	if served != 1 {
+		t.Fatalf("expected the body to be served once, got %d", served)
+	}
+
+	// The details are stored alongside the target, rather than
+	// in the environment.
+	if !file.Exists(target + ".marionette-fetch") {
+		t.Fatalf("missing fetch details")
+	}
+	for key := range env.Variables() {
+		if strings.Contains(key, target) {
+			t.Fatalf("fetch details leaked into the environment: %s", key)
+		}
+	}
+
+	// If the target is changed locally it is replaced.
+	err = ioutil.WriteFile(target, []byte("local edit"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write target: %s", err)
+	}
+	changed, err = f.FetchURL(ts.URL, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+	if served != 2 {
+		t.Fatalf("expected the body to be served twice, got %d", served)
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %s", err)
+	}
+	if string(data) != "remote content" {
+		t.Fatalf("wrong content: %s", data)
+	}
+
+	// An existing target we didn't fetch is also checked.
+	other := filepath.Join(dir, "other")
+	err = ioutil.WriteFile(other, []byte("wrong content"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write target: %s", err)
+	}
+	changed, err = f.FetchURL(ts.URL, other)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	// Failures are errors, and don't touch the target.
+	_, err = f.FetchURL(ts.URL+"/missing", target)
+	if err == nil {
+		t.Fatalf("expected error fetching missing URL")
+	}
+	data, err = ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %s", err)
+	}
+	if string(data) != "remote content" {
+		t.Fatalf("target was changed by a failure: %s", data)
+	}
+
+	// Changing the target by other means removes the details.
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["content"] = "local content"
+	_, err = f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if file.Exists(target + ".marionette-fetch") {
+		t.Fatalf("fetch details remain after the target changed")
+	}
+}
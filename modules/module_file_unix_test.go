@@ -0,0 +1,136 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestModeOnCreation ensures that new content is never visible with the
+// wrong permissions.
+//
+// We copy from a FIFO, so that we can examine the target while the
+// copy is in progress.
+func TestModeOnCreation(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "fifo")
+	dst := filepath.Join(dir, "secret")
+
+	err = syscall.Mkfifo(src, 0600)
+	if err != nil {
+		t.Fatalf("failed to create fifo: %s", err)
+	}
+
+	f := &FileModule{mode: "0600"}
+
+	done := make(chan error)
+	go func() {
+		_, err := f.CopyFile(src, dst)
+		done <- err
+	}()
+
+	// Write the first part of our secret.
+	w, err := os.OpenFile(src, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open fifo: %s", err)
+	}
+	_, err = w.Write([]byte("top"))
+	if err != nil {
+		t.Fatalf("failed to write to fifo: %s", err)
+	}
+
+	// Wait for it to reach the target, which must already have
+	// the correct mode.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := os.Stat(dst)
+		if err == nil && info.Size() > 0 {
+			if info.Mode().Perm() != 0600 {
+				t.Fatalf("secret was visible with mode %o", info.Mode().Perm())
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the copy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Now finish the copy.
+	_, err = w.Write([]byte(" secret"))
+	if err != nil {
+		t.Fatalf("failed to write to fifo: %s", err)
+	}
+	w.Close()
+
+	err = <-done
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("failed to read secret: %s", err)
+	}
+	if string(data) != "top secret" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat secret: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong mode: %o", info.Mode().Perm())
+	}
+}
+
+// TestModeOnUpdate ensures that the mode of an existing file is changed
+// along with its content.
+func TestModeOnUpdate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "secret")
+	err = ioutil.WriteFile(dst, []byte("old"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = dst
+	args["content"] = "new secret"
+	args["mode"] = "go-r"
+
+	f := &FileModule{}
+	changed, err := f.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat file: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong mode: %o", info.Mode().Perm())
+	}
+}
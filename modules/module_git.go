@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	mcfg "github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -22,6 +23,15 @@ type GitModule struct {
 
 	// env holds our environment
 	env *environment.Environment
+
+	// head holds the commit which was checked out, after pulling.
+	head string
+
+	// previous holds the commit which was checked out before pulling.
+	previous string
+
+	// changed records whether the repository was cloned or updated.
+	changed bool
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -44,9 +54,65 @@ func (g *GitModule) Check(args map[string]interface{}) error {
 		}
 
 	}
+
+	// The depth is optional, but must be a positive number if present.
+	_, err := g.depth(args)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// depth returns the depth to clone/fetch with, if one was specified.
+//
+// Zero is returned if no depth was specified, which means the full
+// history will be fetched.
+func (g *GitModule) depth(args map[string]interface{}) (int, error) {
+
+	str := StringParam(args, "depth")
+	if str == "" {
+		return 0, nil
+	}
+
+	depth, err := strconv.Atoi(str)
+	if err != nil || depth < 1 {
+		return 0, fmt.Errorf("'depth' must be a positive number, got '%s'", str)
+	}
+
+	return depth, nil
+}
+
+// discardChanges performs a hard reset of the working tree to the given
+// commit, and removes any untracked files and directories.
+//
+// We return true if there were local changes which were discarded.
+func (g *GitModule) discardChanges(w *git.Worktree, hash plumbing.Hash) (bool, error) {
+
+	// Are there any local changes?
+	status, err := w.Status()
+	if err != nil {
+		return false, fmt.Errorf("git.Status failed %s", err)
+	}
+	if status.IsClean() {
+		return false, nil
+	}
+
+	log.Printf("[INFO] Discarding local changes in %s", w.Filesystem.Root())
+
+	err = w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	if err != nil {
+		return false, fmt.Errorf("git.Reset failed %s", err)
+	}
+
+	err = w.Clean(&git.CleanOptions{Dir: true})
+	if err != nil {
+		return false, fmt.Errorf("git.Clean failed %s", err)
+	}
+
+	return true, nil
+}
+
 // Execute is part of the module-api, and is invoked to run a rule.
 func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 
@@ -58,8 +124,18 @@ func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 	// optional branch to checkout
 	branch := StringParam(args, "branch")
 
+	// discard local changes?
+	force := StringParam(args, "force")
+
+	// optional depth for shallow clones
+	depth, err := g.depth(args)
+	if err != nil {
+		return false, err
+	}
+
 	// Have we changed?
 	changed := false
+	g.changed = false
 
 	// If we don't have "path/.git" then we need to fetch it
 	tmp := filepath.Join(path, ".git")
@@ -71,6 +147,7 @@ func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 		// Clone since it is missing.
 		_, err := git.PlainClone(path, false, &git.CloneOptions{
 			URL:      repo,
+			Depth:    depth,
 			Progress: os.Stdout,
 		})
 
@@ -105,7 +182,18 @@ func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, fmt.Errorf("git.Worktree failed %s", err)
 	}
 
-	options := &git.PullOptions{RemoteName: "origin"}
+	// Discard any local changes, if we should.
+	if force == "yes" || force == "true" {
+		discarded, err := g.discardChanges(w, ref.Hash())
+		if err != nil {
+			return false, err
+		}
+		if discarded {
+			changed = true
+		}
+	}
+
+	options := &git.PullOptions{RemoteName: "origin", Depth: depth}
 
 	// If we're to switch branch do that
 	if branch != "" {
@@ -113,6 +201,7 @@ func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 		// fetch references
 		err = r.Fetch(&git.FetchOptions{
 			RefSpecs: []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
+			Depth:    depth,
 		})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return false, fmt.Errorf("git.Fetch failed %s", err)
@@ -144,14 +233,33 @@ func (g *GitModule) Execute(args map[string]interface{}) (bool, error) {
 
 	log.Printf("[DEBUG] First reference %s, second reference %s", ref.Hash(), ref2.Hash())
 
+	// Save the commits for our outputs.
+	g.previous = ref.Hash().String()
+	g.head = ref2.Hash().String()
+
 	// If the hashes differ we've updated, and thus changed
 	if ref2.Hash() != ref.Hash() {
 		changed = true
 	}
 
+	g.changed = changed
 	return changed, err
 }
 
+// GetOutputs is an optional interface method which allows the
+// module to return values to the caller - prefixed by the rule-name.
+func (g *GitModule) GetOutputs() map[string]string {
+
+	// Prepare a map of key->values to return
+	m := make(map[string]string)
+
+	m["head"] = g.head
+	m["changed"] = fmt.Sprintf("%t", g.changed)
+	m["previous"] = g.previous
+
+	return m
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("git", func(cfg *mcfg.Config, env *environment.Environment) ModuleAPI {
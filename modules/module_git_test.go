@@ -0,0 +1,205 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestGitCheck(t *testing.T) {
+
+	g := &GitModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'repository'
+	err := g.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing repository")
+	}
+	if !strings.Contains(err.Error(), "missing 'repository'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	args["repository"] = "https://github.com/skx/marionette"
+	args["path"] = "/tmp/marionette"
+	err = g.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Valid depth
+	args["depth"] = "1"
+	err = g.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Invalid depths
+	for _, bogus := range []string{"steve", "0", "-3"} {
+		args["depth"] = bogus
+		err = g.Check(args)
+		if err == nil {
+			t.Fatalf("expected error due to bogus depth %s", bogus)
+		}
+	}
+}
+
+func TestGitDiscardChanges(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a repository with a single commit
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to create repository: %s", err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %s", err)
+	}
+
+	readme := filepath.Join(dir, "README")
+	err = ioutil.WriteFile(readme, []byte("original\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	_, err = w.Add("README")
+	if err != nil {
+		t.Fatalf("failed to add file: %s", err)
+	}
+	hash, err := w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	g := &GitModule{}
+
+	// Nothing to discard
+	changed, err := g.discardChanges(w, hash)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("clean tree shouldn't result in a change")
+	}
+
+	// Modify the file, and add an untracked one
+	err = ioutil.WriteFile(readme, []byte("modified\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	extra := filepath.Join(dir, "extra")
+	err = ioutil.WriteFile(extra, []byte("untracked\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	changed, err = g.discardChanges(w, hash)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected local changes to be discarded")
+	}
+
+	content, err := ioutil.ReadFile(readme)
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	if string(content) != "original\n" {
+		t.Fatalf("modification wasn't reset: %s", content)
+	}
+	if _, err = os.Stat(extra); !os.IsNotExist(err) {
+		t.Fatalf("untracked file wasn't removed")
+	}
+}
+
+func TestGitOutputs(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// Create an "upstream" repository with a single commit
+	origin := filepath.Join(dir, "origin")
+	r, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatalf("failed to create repository: %s", err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(origin, "README"), []byte("hello\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	_, err = w.Add("README")
+	if err != nil {
+		t.Fatalf("failed to add file: %s", err)
+	}
+	hash, err := w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["repository"] = origin
+	args["path"] = filepath.Join(dir, "clone")
+
+	g := &GitModule{}
+	changed, err := g.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change from cloning")
+	}
+
+	out := g.GetOutputs()
+	if out["head"] != hash.String() {
+		t.Fatalf("wrong head output: %s != %s", out["head"], hash)
+	}
+	if out["previous"] != hash.String() {
+		t.Fatalf("wrong previous output: %s != %s", out["previous"], hash)
+	}
+	if out["changed"] != "true" {
+		t.Fatalf("wrong changed output: %s", out["changed"])
+	}
+
+	// Running again results in no change
+	changed, err = g.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	out = g.GetOutputs()
+	if out["changed"] != "false" {
+		t.Fatalf("wrong changed output: %s", out["changed"])
+	}
+	if out["head"] != hash.String() {
+		t.Fatalf("wrong head output: %s != %s", out["head"], hash)
+	}
+}
@@ -7,6 +7,7 @@ package modules
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 
 	mcfg "github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -54,6 +55,27 @@ func (g *GroupModule) Check(args map[string]interface{}) error {
 
 	}
 
+	// Ensure any members have decent characters
+	for _, member := range ArrayCastParam(args, "members") {
+		if !g.reg.MatchString(member) {
+			return fmt.Errorf("member '%s' failed validation", member)
+		}
+	}
+
+	// Ensure the GID, if present, is numeric
+	gid := StringParam(args, "gid")
+	if gid != "" {
+		if _, err := strconv.Atoi(gid); err != nil {
+			return fmt.Errorf("parameter 'gid' must be numeric")
+		}
+	}
+
+	// Forcing the GID only makes sense if we have one
+	force := StringParam(args, "force_gid")
+	if (force == "yes" || force == "true") && gid == "" {
+		return fmt.Errorf("parameter 'force_gid' requires 'gid'")
+	}
+
 	// Ensure state is one of "present"/"absent"
 	state := StringParam(args, "state")
 	if state == "absent" {
@@ -0,0 +1,52 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupCheck(t *testing.T) {
+
+	g := Lookup("group", nil, nil)
+
+	type TestCase struct {
+		Args  map[string]interface{}
+		Error string
+	}
+
+	tests := []TestCase{
+		{Args: map[string]interface{}{"state": "present"},
+			Error: "missing 'group'"},
+		{Args: map[string]interface{}{"group": "staff"},
+			Error: "missing 'state'"},
+		{Args: map[string]interface{}{"group": "staff", "state": "maybe"},
+			Error: "state must be one of"},
+		{Args: map[string]interface{}{"group": "staff", "state": "present", "gid": "one"},
+			Error: "parameter 'gid' must be numeric"},
+		{Args: map[string]interface{}{"group": "staff", "state": "present", "members": []string{"steve", "bob;id"}},
+			Error: "member 'bob;id' failed validation"},
+		{Args: map[string]interface{}{"group": "staff", "state": "present", "force_gid": "true"},
+			Error: "parameter 'force_gid' requires 'gid'"},
+		{Args: map[string]interface{}{"group": "staff", "state": "present", "gid": "1000", "force_gid": "true", "members": []string{"steve"}}},
+		{Args: map[string]interface{}{"group": "staff", "state": "absent"}},
+	}
+
+	for _, test := range tests {
+
+		err := g.Check(test.Args)
+
+		if test.Error == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for %v: %s", test.Args, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("expected an error for %v", test.Args)
+		}
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Fatalf("got error - but wrong one : %s", err)
+		}
+	}
+}
@@ -24,8 +24,19 @@ func (g *GroupModule) Execute(args map[string]interface{}) (bool, error) {
 		if state == "present" {
 
 			// We're supposed to create the group, but it
-			// already exists.  Do nothing.
-			return false, nil
+			// already exists.  Ensure the GID and members
+			// are correct.
+			changed, err := g.updateGID(args)
+			if err != nil {
+				return false, err
+			}
+
+			mChanged, err := g.addMembers(args)
+			if err != nil {
+				return false, err
+			}
+
+			return changed || mChanged, nil
 		}
 		if state == "absent" {
 
@@ -50,6 +61,12 @@ func (g *GroupModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, ret
 	}
 
+	// Add any members
+	_, err := g.addMembers(args)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -67,35 +84,105 @@ func (g *GroupModule) createGroup(args map[string]interface{}) error {
 	group := StringParam(args, "group")
 
 	// The creation command
-	cmdArgs := []string{"groupadd", group}
+	cmdArgs := []string{"groupadd"}
 
-	// do we need to enhance our permissions?
-	privs := StringParam(args, "elevate")
-	if privs != "" {
-		cmdArgs = append([]string{privs}, cmdArgs...)
+	// Specific GID?
+	gid := StringParam(args, "gid")
+	if gid != "" {
+		cmdArgs = append(cmdArgs, "-g", gid)
 	}
 
-	// Show what we're doing
-	log.Printf("[DEBUG] Running %s", cmdArgs)
+	cmdArgs = append(cmdArgs, group)
 
-	// Run it
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-	if err := cmd.Start(); err != nil {
-		return err
+	return g.run(args, cmdArgs)
+}
+
+// updateGID ensures that an existing group has the GID which was
+// specified, if one was.
+//
+// Changing the GID of a group is dangerous, as files will retain
+// the old value, so we only do so if `force_gid` is set.
+func (g *GroupModule) updateGID(args map[string]interface{}) (bool, error) {
+
+	gid := StringParam(args, "gid")
+	if gid == "" {
+		return false, nil
 	}
 
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
+	group := StringParam(args, "group")
 
-		if exiterr, ok := err.(*exec.ExitError); ok {
+	grp, err := user.LookupGroup(group)
+	if err != nil {
+		return false, err
+	}
 
-			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-				return fmt.Errorf("exit code was %d", status.ExitStatus())
+	if grp.Gid == gid {
+		return false, nil
+	}
+
+	force := StringParam(args, "force_gid")
+	if force != "yes" && force != "true" {
+		return false, fmt.Errorf("group %s has GID %s, not %s; use 'force_gid' to change it", group, grp.Gid, gid)
+	}
+
+	err = g.run(args, []string{"groupmod", "-g", gid, group})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// addMembers ensures that each of the specified members belongs to
+// the group.
+//
+// Existing members who are not listed are not removed.
+func (g *GroupModule) addMembers(args map[string]interface{}) (bool, error) {
+
+	members := ArrayCastParam(args, "members")
+	if len(members) < 1 {
+		return false, nil
+	}
+
+	group := StringParam(args, "group")
+
+	grp, err := user.LookupGroup(group)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+
+	for _, member := range members {
+
+		u, err := user.Lookup(member)
+		if err != nil {
+			return false, err
+		}
+
+		ids, err := u.GroupIds()
+		if err != nil {
+			return false, err
+		}
+
+		// Already a member?
+		found := false
+		for _, id := range ids {
+			if id == grp.Gid {
+				found = true
 			}
 		}
+		if found {
+			continue
+		}
+
+		err = g.run(args, []string{"gpasswd", "-a", member, group})
+		if err != nil {
+			return false, err
+		}
+		changed = true
 	}
 
-	return nil
+	return changed, nil
 }
 
 // removeGroup removes the local group
@@ -106,6 +193,13 @@ func (g *GroupModule) removeGroup(args map[string]interface{}) error {
 	// The removal command
 	cmdArgs := []string{"groupdel", group}
 
+	return g.run(args, cmdArgs)
+}
+
+// run executes the given command, prefixing it with any privilege
+// helper, and returns an error if it fails.
+func (g *GroupModule) run(args map[string]interface{}, cmdArgs []string) error {
+
 	// do we need to enhance our permissions?
 	privs := StringParam(args, "elevate")
 	if privs != "" {
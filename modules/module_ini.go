@@ -0,0 +1,279 @@
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// IniModule stores our state.
+type IniModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (i *IniModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"target", "key"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	switch state {
+	case "present":
+		_, ok := args["value"]
+		if !ok {
+			return fmt.Errorf("missing 'value' parameter")
+		}
+	case "absent":
+	default:
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (i *IniModule) Execute(args map[string]interface{}) (bool, error) {
+
+	target := StringParam(args, "target")
+	section := StringParam(args, "section")
+	key := StringParam(args, "key")
+	value := StringParam(args, "value")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	// Read the existing lines, if the file exists.
+	var lines []string
+	exists := file.Exists(target)
+
+	if exists {
+		var err error
+		lines, err = i.readLines(target)
+		if err != nil {
+			return false, err
+		}
+	} else if state == "absent" {
+		// Nothing to remove from a missing file.
+		return false, nil
+	}
+
+	// Update the content
+	var out []string
+	if state == "present" {
+		out = i.setKey(lines, section, key, value)
+	} else {
+		out = i.removeKey(lines, section, key)
+	}
+
+	// Write the updated content to a temporary file
+	tmpfile, err := ioutil.TempFile("", "marionette-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	for _, line := range out {
+		_, err = tmpfile.WriteString(line + "\n")
+		if err != nil {
+			return false, err
+		}
+	}
+	tmpfile.Close()
+
+	// If the file existed, and is unchanged, we're done.
+	if exists {
+		identical, err := file.Identical(tmpfile.Name(), target)
+		if err != nil {
+			return false, err
+		}
+
+		if identical {
+			return false, nil
+		}
+	}
+
+	err = file.Copy(tmpfile.Name(), target)
+	return true, err
+}
+
+// readLines returns the lines of the given file.
+func (i *IniModule) readLines(path string) ([]string, error) {
+
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+// sectionName returns the name of the section, if the given line is a
+// section header such as "[mysqld]".
+func (i *IniModule) sectionName(line string) (string, bool) {
+
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		return strings.TrimSpace(line[1 : len(line)-1]), true
+	}
+	return "", false
+}
+
+// keyValue returns the key and value, if the given line is a setting
+// rather than a comment or blank line.
+func (i *IniModule) keyValue(line string) (string, string, bool) {
+
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]), true
+}
+
+// setKey returns the given lines, updated so that the key within the
+// section has the given value.
+//
+// Existing settings are updated in place, otherwise the setting is added
+// to the end of the section - which is created if it is missing.  Keys
+// outside of any section are handled by using an empty section name.
+func (i *IniModule) setKey(lines []string, section string, key string, value string) []string {
+
+	setting := key + " = " + value
+
+	var out []string
+
+	current := ""
+	found := false
+
+	// The index at which we'd insert a new setting.
+	insert := -1
+	if section == "" {
+		insert = 0
+	}
+
+	for _, line := range lines {
+
+		if name, ok := i.sectionName(line); ok {
+			current = name
+			out = append(out, line)
+			if current == section {
+				insert = len(out)
+			}
+			continue
+		}
+
+		if current == section {
+			k, v, ok := i.keyValue(line)
+			if ok && k == key {
+				found = true
+				if v != value {
+					line = setting
+				}
+			}
+
+			// Insert after the last non-blank line.
+			if strings.TrimSpace(line) != "" {
+				insert = len(out) + 1
+			}
+		}
+
+		out = append(out, line)
+	}
+
+	if found {
+		return out
+	}
+
+	// Missing section?  Add it, along with the setting.
+	if insert == -1 {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		return append(out, "["+section+"]", setting)
+	}
+
+	// Otherwise insert the setting.
+	out = append(out, "")
+	copy(out[insert+1:], out[insert:])
+	out[insert] = setting
+	return out
+}
+
+// removeKey returns the given lines, without any setting of the key
+// within the section.
+func (i *IniModule) removeKey(lines []string, section string, key string) []string {
+
+	var out []string
+
+	current := ""
+
+	for _, line := range lines {
+
+		if name, ok := i.sectionName(line); ok {
+			current = name
+		} else if current == section {
+			k, _, ok := i.keyValue(line)
+			if ok && k == key {
+				continue
+			}
+		}
+
+		out = append(out, line)
+	}
+
+	return out
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("ini", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &IniModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIniCheck(t *testing.T) {
+
+	i := &IniModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'target'
+	err := i.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing target")
+	}
+	if !strings.Contains(err.Error(), "missing 'target'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'key'
+	args["target"] = "/tmp/php.ini"
+	err = i.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing key")
+	}
+	if !strings.Contains(err.Error(), "missing 'key'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'value'
+	args["key"] = "memory_limit"
+	err = i.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing value")
+	}
+	if !strings.Contains(err.Error(), "missing 'value'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// The value isn't required for removal
+	args["state"] = "absent"
+	err = i.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus state
+	args["state"] = "bogus"
+	err = i.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+}
+
+func TestIniExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "my.cnf")
+	err = ioutil.WriteFile(target, []byte(`; global settings
+user = mysql
+
+[client]
+port = 3306
+
+[mysqld]
+# the port
+port=3306
+bind-address = 127.0.0.1
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	type TestCase struct {
+		Section string
+		Key     string
+		Value   string
+		State   string
+		Changed bool
+		Output  string
+	}
+
+	tests := []TestCase{
+		// unchanged, despite the spacing
+		{Section: "mysqld", Key: "port", Value: "3306", Changed: false},
+
+		// updated in place, in the correct section
+		{Section: "mysqld", Key: "port", Value: "3307", Changed: true,
+			Output: `; global settings
+user = mysql
+
+[client]
+port = 3306
+
+[mysqld]
+# the port
+port = 3307
+bind-address = 127.0.0.1
+`},
+
+		// added to the end of an existing section
+		{Section: "client", Key: "socket", Value: "/tmp/mysql.sock", Changed: true,
+			Output: `; global settings
+user = mysql
+
+[client]
+port = 3306
+socket = /tmp/mysql.sock
+
+[mysqld]
+# the port
+port = 3307
+bind-address = 127.0.0.1
+`},
+
+		// global settings come before any section
+		{Section: "", Key: "debug", Value: "true", Changed: true,
+			Output: `; global settings
+user = mysql
+debug = true
+
+[client]
+port = 3306
+socket = /tmp/mysql.sock
+
+[mysqld]
+# the port
+port = 3307
+bind-address = 127.0.0.1
+`},
+
+		// new sections are created
+		{Section: "mysqldump", Key: "quick", Value: "yes", Changed: true,
+			Output: `; global settings
+user = mysql
+debug = true
+
+[client]
+port = 3306
+socket = /tmp/mysql.sock
+
+[mysqld]
+# the port
+port = 3307
+bind-address = 127.0.0.1
+
+[mysqldump]
+quick = yes
+`},
+
+		// removal only affects the given section
+		{Section: "client", Key: "port", State: "absent", Changed: true,
+			Output: `; global settings
+user = mysql
+debug = true
+
+[client]
+socket = /tmp/mysql.sock
+
+[mysqld]
+# the port
+port = 3307
+bind-address = 127.0.0.1
+
+[mysqldump]
+quick = yes
+`},
+
+		// removing a missing key is not a change
+		{Section: "client", Key: "port", State: "absent", Changed: false},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["target"] = target
+		args["section"] = test.Section
+		args["key"] = test.Key
+		args["value"] = test.Value
+		args["state"] = test.State
+
+		i := &IniModule{}
+		changed, err := i.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+
+		if test.Output != "" {
+			data, err := ioutil.ReadFile(target)
+			if err != nil {
+				t.Fatalf("failed to read file: %s", err)
+			}
+			if string(data) != test.Output {
+				t.Fatalf("unexpected output for %v:\n%s", test, data)
+			}
+		}
+	}
+
+	// A missing file is created
+	missing := filepath.Join(dir, "php.ini")
+
+	args := make(map[string]interface{})
+	args["target"] = missing
+	args["section"] = "PHP"
+	args["key"] = "memory_limit"
+	args["value"] = "256M"
+
+	i := &IniModule{}
+	changed, err := i.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change creating the file")
+	}
+
+	data, err := ioutil.ReadFile(missing)
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	if string(data) != "[PHP]\nmemory_limit = 256M\n" {
+		t.Fatalf("unexpected output:\n%s", data)
+	}
+}
@@ -0,0 +1,344 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// jsonObject is a JSON object which remembers the order of its keys, so
+// that we can write it back out without shuffling the contents.
+type jsonObject struct {
+
+	// keys holds the names of the keys, in order.
+	keys []string
+
+	// values holds the values of the keys.
+	values map[string]interface{}
+}
+
+// JSONModule stores our state.
+type JSONModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (j *JSONModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"target", "key"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	switch state {
+	case "present":
+		_, ok := args["value"]
+		if !ok {
+			return fmt.Errorf("missing 'value' parameter")
+		}
+	case "absent":
+	default:
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (j *JSONModule) Execute(args map[string]interface{}) (bool, error) {
+
+	target := StringParam(args, "target")
+	path := strings.Split(StringParam(args, "key"), ".")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	// Read the existing document, if there is one.
+	var doc interface{}
+	indent := "  "
+
+	if file.Exists(target) {
+
+		data, err := ioutil.ReadFile(target)
+		if err != nil {
+			return false, err
+		}
+
+		doc, err = j.decode(data)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse %s: %s", target, err)
+		}
+		indent = j.indentation(data)
+
+	} else {
+
+		// Nothing to remove from a missing file.
+		if state == "absent" {
+			return false, nil
+		}
+		doc = &jsonObject{values: make(map[string]interface{})}
+	}
+
+	obj, ok := doc.(*jsonObject)
+	if !ok {
+		return false, fmt.Errorf("%s doesn't contain a JSON object", target)
+	}
+
+	// Encode the document as it stands, so we can see if we
+	// change it.
+	before := j.encode(obj, indent)
+
+	if state == "present" {
+
+		// The value is JSON, if it is valid, otherwise a string.
+		val, err := j.decode([]byte(StringParam(args, "value")))
+		if err != nil {
+			val = StringParam(args, "value")
+		}
+
+		err = j.set(obj, path, val)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		j.remove(obj, path)
+	}
+
+	after := j.encode(obj, indent)
+	if after == before {
+		return false, nil
+	}
+
+	err := ioutil.WriteFile(target, []byte(after), 0644)
+	return err == nil, err
+}
+
+// set sets the value at the given path, creating any intermediate objects
+// which are missing.
+func (j *JSONModule) set(obj *jsonObject, path []string, val interface{}) error {
+
+	for i, key := range path[:len(path)-1] {
+
+		cur, ok := obj.values[key]
+		if !ok {
+			cur = &jsonObject{values: make(map[string]interface{})}
+			obj.keys = append(obj.keys, key)
+			obj.values[key] = cur
+		}
+
+		next, ok := cur.(*jsonObject)
+		if !ok {
+			return fmt.Errorf("'%s' is not an object", strings.Join(path[:i+1], "."))
+		}
+		obj = next
+	}
+
+	key := path[len(path)-1]
+	if _, ok := obj.values[key]; !ok {
+		obj.keys = append(obj.keys, key)
+	}
+	obj.values[key] = val
+	return nil
+}
+
+// remove deletes the value at the given path, if it exists.
+func (j *JSONModule) remove(obj *jsonObject, path []string) {
+
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj.values[key].(*jsonObject)
+		if !ok {
+			return
+		}
+		obj = next
+	}
+
+	key := path[len(path)-1]
+	if _, ok := obj.values[key]; !ok {
+		return
+	}
+
+	delete(obj.values, key)
+	for i, k := range obj.keys {
+		if k == key {
+			obj.keys = append(obj.keys[:i], obj.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// decode parses the given JSON, preserving the order of object keys.
+func (j *JSONModule) decode(data []byte) (interface{}, error) {
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	val, err := j.decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure there is nothing trailing.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected content after JSON value")
+	}
+
+	return val, nil
+}
+
+// decodeValue parses the next value from the decoder.
+func (j *JSONModule) decodeValue(dec *json.Decoder) (interface{}, error) {
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{values: make(map[string]interface{})}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := k.(string)
+
+			val, err := j.decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = val
+		}
+		_, err = dec.Token()
+		return obj, err
+
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			val, err := j.decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+
+	return tok, nil
+}
+
+// encode returns the given value as indented JSON, with a trailing
+// newline.
+func (j *JSONModule) encode(val interface{}, indent string) string {
+
+	var buf strings.Builder
+	j.encodeValue(&buf, val, indent, "")
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// encodeValue writes the given value to the buffer, as JSON.
+func (j *JSONModule) encodeValue(buf *strings.Builder, val interface{}, indent string, prefix string) {
+
+	switch v := val.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for i, key := range v.keys {
+			buf.WriteString(prefix + indent)
+			j.encodeValue(buf, key, indent, prefix+indent)
+			buf.WriteString(": ")
+			j.encodeValue(buf, v.values[key], indent, prefix+indent)
+			if i < len(v.keys)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(prefix + "}")
+
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, item := range v {
+			buf.WriteString(prefix + indent)
+			j.encodeValue(buf, item, indent, prefix+indent)
+			if i < len(v)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(prefix + "]")
+
+	default:
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		buf.WriteString(strings.TrimSuffix(out.String(), "\n"))
+	}
+}
+
+// indentation returns the indentation used by the given JSON document,
+// defaulting to two spaces.
+func (j *JSONModule) indentation(data []byte) string {
+
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+
+	return "  "
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("json", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &JSONModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
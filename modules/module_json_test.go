@@ -0,0 +1,226 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONCheck(t *testing.T) {
+
+	j := &JSONModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'target'
+	err := j.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing target")
+	}
+	if !strings.Contains(err.Error(), "missing 'target'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'key'
+	args["target"] = "/tmp/package.json"
+	err = j.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing key")
+	}
+	if !strings.Contains(err.Error(), "missing 'key'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'value'
+	args["key"] = "scripts.test"
+	err = j.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing value")
+	}
+	if !strings.Contains(err.Error(), "missing 'value'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// The value isn't required for removal
+	args["state"] = "absent"
+	err = j.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus state
+	args["state"] = "bogus"
+	err = j.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+}
+
+func TestJSONExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "package.json")
+	err = ioutil.WriteFile(target, []byte(`{
+    "name": "app",
+    "version": "1.0.0",
+    "scripts": {
+        "build": "make <all>"
+    },
+    "files": [ "a", "b" ]
+}
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	type TestCase struct {
+		Key     string
+		Value   string
+		State   string
+		Changed bool
+		Output  string
+		Error   string
+	}
+
+	tests := []TestCase{
+		// unchanged
+		{Key: "version", Value: "1.0.0", Changed: false},
+
+		// updated in place, keeping the order and indentation
+		{Key: "version", Value: "1.0.1", Changed: true,
+			Output: `{
+    "name": "app",
+    "version": "1.0.1",
+    "scripts": {
+        "build": "make <all>"
+    },
+    "files": [
+        "a",
+        "b"
+    ]
+}
+`},
+
+		// values are JSON, if valid
+		{Key: "private", Value: "true", Changed: true},
+		{Key: "scripts.test", Value: `"go test ./..."`, Changed: true},
+
+		// intermediate objects are created
+		{Key: "config.port", Value: "8080", Changed: true,
+			Output: `{
+    "name": "app",
+    "version": "1.0.1",
+    "scripts": {
+        "build": "make <all>",
+        "test": "go test ./..."
+    },
+    "files": [
+        "a",
+        "b"
+    ],
+    "private": true,
+    "config": {
+        "port": 8080
+    }
+}
+`},
+
+		// we can't descend into non-objects
+		{Key: "name.first", Value: "steve", Error: "'name' is not an object"},
+
+		// removal
+		{Key: "scripts.build", State: "absent", Changed: true},
+		{Key: "scripts.build", State: "absent", Changed: false},
+		{Key: "missing.key", State: "absent", Changed: false},
+		{Key: "files", State: "absent", Changed: true,
+			Output: `{
+    "name": "app",
+    "version": "1.0.1",
+    "scripts": {
+        "test": "go test ./..."
+    },
+    "private": true,
+    "config": {
+        "port": 8080
+    }
+}
+`},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["target"] = target
+		args["key"] = test.Key
+		args["value"] = test.Value
+		args["state"] = test.State
+
+		j := &JSONModule{}
+		changed, err := j.Execute(args)
+		if test.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Fatalf("expected error %s, got %v", test.Error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+
+		if test.Output != "" {
+			data, err := ioutil.ReadFile(target)
+			if err != nil {
+				t.Fatalf("failed to read file: %s", err)
+			}
+			if string(data) != test.Output {
+				t.Fatalf("unexpected output for %v:\n%s", test, data)
+			}
+		}
+	}
+
+	// A missing file is created
+	missing := filepath.Join(dir, "config.json")
+
+	args := make(map[string]interface{})
+	args["target"] = missing
+	args["key"] = "server.name"
+	args["value"] = "example.com"
+
+	j := &JSONModule{}
+	changed, err := j.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change creating the file")
+	}
+
+	data, err := ioutil.ReadFile(missing)
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	if string(data) != "{\n  \"server\": {\n    \"name\": \"example.com\"\n  }\n}\n" {
+		t.Fatalf("unexpected output:\n%s", data)
+	}
+
+	// Invalid JSON is an error
+	err = ioutil.WriteFile(missing, []byte("{ broken"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	_, err = j.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error with invalid JSON")
+	}
+}
@@ -0,0 +1,353 @@
+// This module handles the installation/removal of language-level packages,
+// via pip, npm, or gem.
+
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+)
+
+// LangPackageModule stores our state
+type LangPackageModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+
+	// run executes a command within the given directory, returning
+	// the output.  It may be replaced for testing purposes.
+	run func(dir string, cmd []string) ([]byte, error)
+
+	// installed holds the packages we installed, for our outputs.
+	installed []string
+
+	// removed holds the packages we removed, for our outputs.
+	removed []string
+}
+
+// langManagers contains the package-managers we support.
+var langManagers = []string{"gem", "npm", "pip"}
+
+// Check is part of the module-api, and checks arguments.
+func (lp *LangPackageModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"manager", "package"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+	}
+
+	manager := StringParam(args, "manager")
+	known := false
+	for _, m := range langManagers {
+		if m == manager {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("manager must be one of '%s', got '%s'", strings.Join(langManagers, "', '"), manager)
+	}
+
+	// A version only makes sense for a single package.
+	if StringParam(args, "version") != "" && len(ArrayCastParam(args, "package")) != 1 {
+		return fmt.Errorf("'version' may only be used with a single package")
+	}
+
+	state := StringParam(args, "state")
+	if state != "" && state != "installed" && state != "absent" {
+		return fmt.Errorf("package state must be either 'installed' or 'absent'")
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (lp *LangPackageModule) Execute(args map[string]interface{}) (bool, error) {
+
+	manager := StringParam(args, "manager")
+	version := StringParam(args, "version")
+	packages := ArrayCastParam(args, "package")
+
+	// Packages are installed globally, unless we have a directory.
+	cwd := StringParam(args, "cwd")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "installed"
+	}
+
+	toInstall := []string{}
+	toRemove := []string{}
+
+	for _, name := range packages {
+
+		log.Printf("[DEBUG] Testing %s package %s", manager, name)
+
+		current, err := lp.installedVersion(manager, name, cwd)
+		if err != nil {
+			return false, err
+		}
+
+		log.Printf("[DEBUG] %s package %s has version '%s'", manager, name, current)
+
+		if state == "installed" {
+
+			// Already installed, with the correct version?
+			if current != "" && (version == "" || current == version) {
+				continue
+			}
+			toInstall = append(toInstall, name)
+		}
+
+		if state == "absent" && current != "" {
+			toRemove = append(toRemove, name)
+		}
+	}
+
+	changed := false
+
+	if len(toInstall) > 0 {
+
+		cmd := lp.installCommand(manager, toInstall, version, cwd)
+		_, err := lp.execute(cwd, cmd, args)
+		if err != nil {
+			return false, err
+		}
+
+		changed = true
+		lp.installed = toInstall
+	}
+
+	if len(toRemove) > 0 {
+
+		cmd := lp.removeCommand(manager, toRemove, cwd)
+		_, err := lp.execute(cwd, cmd, args)
+		if err != nil {
+			return false, err
+		}
+
+		changed = true
+		lp.removed = toRemove
+	}
+
+	return changed, nil
+}
+
+// installedVersion returns the installed version of the named package,
+// or the empty string if it is not installed.
+func (lp *LangPackageModule) installedVersion(manager string, name string, cwd string) (string, error) {
+
+	switch manager {
+	case "pip":
+		// "pip show" fails if the package isn't installed.
+		out, err := lp.runCommand(cwd, []string{"pip", "show", name})
+		if err != nil {
+			return "", nil
+		}
+		return parsePipShow(out), nil
+
+	case "npm":
+		cmd := []string{"npm", "ls", "--depth=0", "--json"}
+		if cwd == "" {
+			cmd = append(cmd, "--global")
+		}
+		cmd = append(cmd, name)
+
+		// "npm ls" fails if the package isn't installed, but
+		// still outputs valid JSON.
+		out, _ := lp.runCommand(cwd, cmd)
+		return parseNpmList(out, name)
+
+	case "gem":
+		out, err := lp.runCommand(cwd, []string{"gem", "list", "--local", "--exact", name})
+		if err != nil {
+			return "", err
+		}
+		return parseGemList(out, name), nil
+	}
+
+	return "", fmt.Errorf("unknown package manager '%s'", manager)
+}
+
+// installCommand returns the command to install the given packages.
+func (lp *LangPackageModule) installCommand(manager string, names []string, version string, cwd string) []string {
+
+	switch manager {
+	case "pip":
+		cmd := []string{"pip", "install"}
+		for _, name := range names {
+			if version != "" {
+				name += "==" + version
+			}
+			cmd = append(cmd, name)
+		}
+		return cmd
+
+	case "npm":
+		cmd := []string{"npm", "install"}
+		if cwd == "" {
+			cmd = append(cmd, "--global")
+		}
+		for _, name := range names {
+			if version != "" {
+				name += "@" + version
+			}
+			cmd = append(cmd, name)
+		}
+		return cmd
+
+	case "gem":
+		cmd := append([]string{"gem", "install"}, names...)
+		if version != "" {
+			cmd = append(cmd, "--version", version)
+		}
+		return cmd
+	}
+
+	return nil
+}
+
+// removeCommand returns the command to remove the given packages.
+func (lp *LangPackageModule) removeCommand(manager string, names []string, cwd string) []string {
+
+	switch manager {
+	case "pip":
+		return append([]string{"pip", "uninstall", "--yes"}, names...)
+
+	case "npm":
+		cmd := []string{"npm", "uninstall"}
+		if cwd == "" {
+			cmd = append(cmd, "--global")
+		}
+		return append(cmd, names...)
+
+	case "gem":
+		return append([]string{"gem", "uninstall", "--all", "--executables"}, names...)
+	}
+
+	return nil
+}
+
+// execute runs the given command, which will change the system, using
+// any privilege helper which was specified.
+func (lp *LangPackageModule) execute(cwd string, cmd []string, args map[string]interface{}) ([]byte, error) {
+
+	// Do we need to use doas/sudo?
+	privs := StringParam(args, "elevate")
+	if privs != "" {
+		cmd = append([]string{privs}, cmd...)
+	}
+
+	log.Printf("[DEBUG] CMD: %s", strings.Join(cmd, " "))
+
+	out, err := lp.runCommand(cwd, cmd)
+	if err != nil {
+		return out, fmt.Errorf("error running command '%s' %s", strings.Join(cmd, " "), err.Error())
+	}
+	return out, nil
+}
+
+// runCommand runs the given command, returning the output.
+func (lp *LangPackageModule) runCommand(cwd string, cmd []string) ([]byte, error) {
+
+	if lp.run != nil {
+		return lp.run(cwd, cmd)
+	}
+
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Dir = cwd
+
+	var out bytes.Buffer
+	c.Stdout = &out
+
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// parsePipShow returns the version from the output of "pip show".
+func parsePipShow(out []byte) string {
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+	}
+	return ""
+}
+
+// parseNpmList returns the version of the named package from the JSON
+// output of "npm ls".
+func parseNpmList(out []byte, name string) (string, error) {
+
+	if len(bytes.TrimSpace(out)) == 0 {
+		return "", nil
+	}
+
+	var list struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+
+	err := json.Unmarshal(out, &list)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse npm output: %s", err)
+	}
+
+	return list.Dependencies[name].Version, nil
+}
+
+// parseGemList returns the newest version of the named package from the
+// output of "gem list", which looks like "rake (13.0.6, 12.3.3)".
+func parseGemList(out []byte, name string) string {
+
+	for _, line := range strings.Split(string(out), "\n") {
+
+		if !strings.HasPrefix(line, name+" (") {
+			continue
+		}
+
+		versions := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(line), name+" ("), ")")
+		versions = strings.TrimPrefix(versions, "default: ")
+		return strings.TrimSpace(strings.Split(versions, ",")[0])
+	}
+	return ""
+}
+
+// GetOutputs is an optional interface method which allows the
+// module to return values to the caller - prefixed by the rule-name.
+func (lp *LangPackageModule) GetOutputs() map[string]string {
+
+	// Prepare a map of key->values to return
+	m := make(map[string]string)
+
+	m["installed"] = strings.Join(lp.installed, ",")
+	m["removed"] = strings.Join(lp.removed, ",")
+
+	return m
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("langpkg", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &LangPackageModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
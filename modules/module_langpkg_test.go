@@ -0,0 +1,199 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLangPackageCheck(t *testing.T) {
+
+	lp := &LangPackageModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'manager'
+	err := lp.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing manager")
+	}
+	if !strings.Contains(err.Error(), "missing 'manager'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'package'
+	args["manager"] = "pip"
+	err = lp.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing package")
+	}
+	if !strings.Contains(err.Error(), "missing 'package'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Bogus manager
+	args["package"] = "requests"
+	args["manager"] = "cpan"
+	err = lp.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus manager")
+	}
+
+	// Version with multiple packages
+	args["manager"] = "pip"
+	args["package"] = []string{"requests", "flask"}
+	args["version"] = "1.0"
+	err = lp.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to version with multiple packages")
+	}
+
+	// Bogus state
+	args["package"] = "requests"
+	args["state"] = "bogus"
+	err = lp.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+
+	args["state"] = "absent"
+	err = lp.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestLangPackageParse(t *testing.T) {
+
+	pip := "Name: requests\nVersion: 2.31.0\nSummary: HTTP\n"
+	if parsePipShow([]byte(pip)) != "2.31.0" {
+		t.Fatalf("wrong pip version")
+	}
+	if parsePipShow([]byte("")) != "" {
+		t.Fatalf("wrong pip version")
+	}
+
+	npm := `{"dependencies": {"left-pad": {"version": "1.3.0"}}}`
+	ver, err := parseNpmList([]byte(npm), "left-pad")
+	if err != nil || ver != "1.3.0" {
+		t.Fatalf("wrong npm version: %s %v", ver, err)
+	}
+	ver, err = parseNpmList([]byte(`{}`), "left-pad")
+	if err != nil || ver != "" {
+		t.Fatalf("wrong npm version: %s %v", ver, err)
+	}
+	_, err = parseNpmList([]byte(`{`), "left-pad")
+	if err == nil {
+		t.Fatalf("expected error parsing bogus JSON")
+	}
+
+	gem := "\n*** LOCAL GEMS ***\n\nrake (13.0.6, 12.3.3)\n"
+	if parseGemList([]byte(gem), "rake") != "13.0.6" {
+		t.Fatalf("wrong gem version")
+	}
+	if parseGemList([]byte("bundler (default: 2.4.10)\n"), "bundler") != "2.4.10" {
+		t.Fatalf("wrong default gem version")
+	}
+	if parseGemList([]byte(gem), "rails") != "" {
+		t.Fatalf("wrong gem version")
+	}
+}
+
+func TestLangPackageExecute(t *testing.T) {
+
+	// The versions of the installed packages
+	installed := map[string]string{"requests": "2.0"}
+
+	// The commands we ran which changed things
+	var ran []string
+
+	lp := &LangPackageModule{}
+	lp.run = func(dir string, cmd []string) ([]byte, error) {
+
+		switch cmd[1] {
+		case "show":
+			ver, ok := installed[cmd[2]]
+			if !ok {
+				return nil, fmt.Errorf("not installed")
+			}
+			return []byte("Version: " + ver + "\n"), nil
+		case "install":
+			installed["requests"] = "2.31.0"
+		case "uninstall":
+			delete(installed, "requests")
+		}
+
+		ran = append(ran, strings.Join(cmd, " "))
+		return nil, nil
+	}
+
+	type TestCase struct {
+		Version string
+		State   string
+		Changed bool
+		Command string
+	}
+
+	tests := []TestCase{
+		{Changed: false},
+		{Version: "2.31.0", Changed: true, Command: "pip install requests==2.31.0"},
+		{Version: "2.31.0", Changed: false},
+		{State: "absent", Changed: true, Command: "pip uninstall --yes requests"},
+		{State: "absent", Changed: false},
+	}
+
+	for _, test := range tests {
+
+		ran = []string{}
+
+		args := make(map[string]interface{})
+		args["manager"] = "pip"
+		args["package"] = "requests"
+		args["version"] = test.Version
+		args["state"] = test.State
+
+		changed, err := lp.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+		if test.Command != "" && (len(ran) != 1 || ran[0] != test.Command) {
+			t.Fatalf("unexpected commands for %v: %v", test, ran)
+		}
+	}
+}
+
+func TestLangPackageCommands(t *testing.T) {
+
+	lp := &LangPackageModule{}
+
+	type TestCase struct {
+		Manager string
+		Cwd     string
+		Install string
+		Remove  string
+	}
+
+	tests := []TestCase{
+		{Manager: "npm", Install: "npm install --global left-pad@1.3.0",
+			Remove: "npm uninstall --global left-pad"},
+		{Manager: "npm", Cwd: "/srv/app", Install: "npm install left-pad@1.3.0",
+			Remove: "npm uninstall left-pad"},
+		{Manager: "gem", Install: "gem install left-pad --version 1.3.0",
+			Remove: "gem uninstall --all --executables left-pad"},
+	}
+
+	for _, test := range tests {
+
+		out := strings.Join(lp.installCommand(test.Manager, []string{"left-pad"}, "1.3.0", test.Cwd), " ")
+		if out != test.Install {
+			t.Fatalf("wrong install command: %s", out)
+		}
+		out = strings.Join(lp.removeCommand(test.Manager, []string{"left-pad"}, test.Cwd), " ")
+		if out != test.Remove {
+			t.Fatalf("wrong remove command: %s", out)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
-	"github.com/skx/marionette/file"
 )
 
 // LinkModule stores our state
@@ -22,7 +21,11 @@ type LinkModule struct {
 // Check is part of the module-api, and checks arguments.
 func (f *LinkModule) Check(args map[string]interface{}) error {
 
+	// The source is only required when we're creating a link.
 	required := []string{"source", "target"}
+	if StringParam(args, "state") == "absent" {
+		required = []string{"target"}
+	}
 
 	for _, key := range required {
 		_, ok := args[key]
@@ -31,6 +34,12 @@ func (f *LinkModule) Check(args map[string]interface{}) error {
 		}
 	}
 
+	// Ensure state is one of "present"/"absent", if specified.
+	state := StringParam(args, "state")
+	if state != "" && state != "present" && state != "absent" {
+		return fmt.Errorf("state must be one of 'absent' or 'present'")
+	}
+
 	return nil
 }
 
@@ -43,21 +52,37 @@ func (f *LinkModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, fmt.Errorf("failed to convert target to string")
 	}
 
+	// Are we removing the link?
+	if StringParam(args, "state") == "absent" {
+		return f.removeLink(target)
+	}
+
 	// Get the source
 	source := StringParam(args, "source")
 	if source == "" {
 		return false, fmt.Errorf("failed to convert source to string")
 	}
 
-	// If the target doesn't exist we create the link.
-	if !file.Exists(target) {
-		err := os.Symlink(source, target)
-		return true, err
+	// Should we replace things which are not symlinks?
+	force := StringParam(args, "force")
+
+	// Are we creating a hard link?
+	hard := StringParam(args, "hard")
+	if hard == "yes" || hard == "true" {
+		return f.hardLink(source, target, force == "yes" || force == "true")
 	}
 
 	// If the target does exist see if it is correct.
+	//
+	// NOTE: We use Lstat so that we find dangling symlinks.
 	fileInfo, err := os.Lstat(target)
 
+	// If the target doesn't exist we create the link.
+	if os.IsNotExist(err) {
+		err = os.Symlink(source, target)
+		return true, err
+	}
+
 	if err != nil {
 		return false, err
 	}
@@ -87,7 +112,11 @@ func (f *LinkModule) Execute(args map[string]interface{}) (bool, error) {
 
 		// We found something that wasn't a symlink.
 		//
-		// Remove it.
+		// We only remove it if we've been told to.
+		if force != "yes" && force != "true" {
+			return false, fmt.Errorf("%s exists and is not a symlink, use 'force' to replace it", target)
+		}
+
 		err = os.Remove(target)
 		if err != nil {
 			return false, err
@@ -100,6 +129,71 @@ func (f *LinkModule) Execute(args map[string]interface{}) (bool, error) {
 	return true, err
 }
 
+// hardLink ensures that the target is a hard link to the source.
+//
+// If the target exists, and is not already a link to the same file as
+// the source, we'll replace it - if it is a symlink, or force is set.
+func (f *LinkModule) hardLink(source string, target string, force bool) (bool, error) {
+
+	// The source must exist for us to link to it.
+	srcInfo, err := os.Stat(source)
+	if err != nil {
+		return false, err
+	}
+
+	// If the target doesn't exist we create the link.
+	fileInfo, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		err = os.Link(source, target)
+		return true, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Is the target already the same file as the source?
+	if os.SameFile(srcInfo, fileInfo) {
+		return false, nil
+	}
+
+	// Symlinks are replaced, but other things require force.
+	if fileInfo.Mode()&os.ModeSymlink == 0 && !force {
+		return false, fmt.Errorf("%s exists and is not a link to %s, use 'force' to replace it", target, source)
+	}
+
+	err = os.Remove(target)
+	if err != nil {
+		return false, err
+	}
+
+	// Create the link.
+	err = os.Link(source, target)
+	return true, err
+}
+
+// removeLink removes the given symlink, if it exists.
+//
+// We refuse to remove anything which is not a symlink.
+func (f *LinkModule) removeLink(target string) (bool, error) {
+
+	fileInfo, err := os.Lstat(target)
+
+	// Doesn't exist?  Then there's nothing to do.
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if fileInfo.Mode()&os.ModeSymlink == 0 {
+		return false, fmt.Errorf("%s exists and is not a symlink, refusing to remove it", target)
+	}
+
+	err = os.Remove(target)
+	return err == nil, err
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("link", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
@@ -0,0 +1,219 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkCheck(t *testing.T) {
+
+	l := &LinkModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'source'
+	args["target"] = "/tmp/foo"
+	err := l.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing source")
+	}
+	if !strings.Contains(err.Error(), "missing 'source'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// The source isn't required for removal
+	args["state"] = "absent"
+	err = l.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus state
+	args["state"] = "bogus"
+	err = l.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+}
+
+func TestLinkForce(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a regular file where our link should go
+	target := filepath.Join(dir, "target")
+	err = ioutil.WriteFile(target, []byte("user data"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["source"] = "/etc/passwd"
+	args["target"] = target
+
+	// Without force we refuse to replace the file
+	l := &LinkModule{}
+	_, err = l.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error replacing a regular file")
+	}
+	if !strings.Contains(err.Error(), "use 'force'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil || string(data) != "user data" {
+		t.Fatalf("file was modified without force")
+	}
+
+	// With force we replace it
+	args["force"] = "true"
+	changed, err := l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	dst, err := os.Readlink(target)
+	if err != nil {
+		t.Fatalf("target is not a symlink: %s", err)
+	}
+	if dst != "/etc/passwd" {
+		t.Fatalf("symlink points to the wrong place: %s", dst)
+	}
+}
+
+func TestLinkAbsent(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "link")
+	err = os.Symlink("/etc/passwd", target)
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	args := make(map[string]interface{})
+	args["target"] = target
+	args["state"] = "absent"
+
+	// Removing the link is a change
+	l := &LinkModule{}
+	changed, err := l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	_, err = os.Lstat(target)
+	if !os.IsNotExist(err) {
+		t.Fatalf("symlink still exists")
+	}
+
+	// Removing it again is not
+	changed, err = l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	// We refuse to remove a regular file
+	err = ioutil.WriteFile(target, []byte("user data"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	_, err = l.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error removing a regular file")
+	}
+}
+
+func TestLinkHard(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+	err = ioutil.WriteFile(source, []byte("shared data"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	target := filepath.Join(dir, "target")
+
+	args := make(map[string]interface{})
+	args["source"] = source
+	args["target"] = target
+	args["hard"] = "true"
+
+	// The first run creates the link
+	l := &LinkModule{}
+	changed, err := l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+
+	a, _ := os.Stat(source)
+	b, _ := os.Lstat(target)
+	if !os.SameFile(a, b) {
+		t.Fatalf("target is not a hard link to the source")
+	}
+
+	// The second run does nothing
+	changed, err = l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if changed {
+		t.Fatalf("didn't expect a change, but got one")
+	}
+
+	// A different file requires force
+	err = os.Remove(target)
+	if err != nil {
+		t.Fatalf("failed to remove target: %s", err)
+	}
+	err = ioutil.WriteFile(target, []byte("other data"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	_, err = l.Execute(args)
+	if err == nil {
+		t.Fatalf("expected an error replacing a regular file")
+	}
+
+	args["force"] = "true"
+	changed, err = l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected a change")
+	}
+}
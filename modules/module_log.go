@@ -3,6 +3,7 @@ package modules
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -27,9 +28,33 @@ func (f *LogModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("missing 'message' parameter")
 	}
 
+	// Ensure the level, if present, is valid.
+	_, err := f.level(args)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// level returns the log-level prefix to use for our messages.
+//
+// If no level was specified we default to "USER".
+func (f *LogModule) level(args map[string]interface{}) (string, error) {
+
+	level := StringParam(args, "level")
+	if level == "" {
+		return "USER", nil
+	}
+
+	switch strings.ToLower(level) {
+	case "debug", "info", "user", "error":
+		return strings.ToUpper(level), nil
+	}
+
+	return "", fmt.Errorf("unknown level '%s' - expected debug, info, user, or error", level)
+}
+
 // Execute is part of the module-api, and is invoked to run a rule.
 func (f *LogModule) Execute(args map[string]interface{}) (bool, error) {
 
@@ -41,9 +66,15 @@ func (f *LogModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, fmt.Errorf("missing 'message' parameter")
 	}
 
+	// Get the level to log at.
+	level, err := f.level(args)
+	if err != nil {
+		return false, err
+	}
+
 	// process each argument
 	for _, str := range strs {
-		log.Print("[USER] " + str)
+		log.Print("[" + level + "] " + str)
 	}
 
 	return true, nil
@@ -71,3 +71,43 @@ func TestLogArguments(t *testing.T) {
 	}
 
 }
+
+// Test the log-level is honored.
+func TestLogLevel(t *testing.T) {
+
+	// Save our log writer
+	before := log.Writer()
+	defer log.SetOutput(before)
+
+	// Change logger to write to a temporary buffer.
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	l := &LogModule{}
+	args := make(map[string]interface{})
+	args["message"] = "Something broke"
+
+	// Bogus level
+	args["level"] = "steve"
+	err := l.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus level")
+	}
+
+	// Valid level
+	args["level"] = "error"
+	err = l.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error checking: %s", err)
+	}
+
+	_, err = l.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error executing: %s", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "[ERROR] Something broke") {
+		t.Fatalf("log message had the wrong level: %s", output)
+	}
+}
@@ -23,6 +23,12 @@ type PackageModule struct {
 
 	// state when using a compatibility-module
 	state string
+
+	// installed holds the packages we installed, for our outputs.
+	installed []string
+
+	// removed holds the packages we removed, for our outputs.
+	removed []string
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -160,6 +166,7 @@ func (pm *PackageModule) Execute(args map[string]interface{}) (bool, error) {
 		// We resulted in a change, because we had things to install
 		// and presumably they're now installed.
 		changed = true
+		pm.installed = toInstall
 	}
 
 	// Something to uninstall?
@@ -177,11 +184,25 @@ func (pm *PackageModule) Execute(args map[string]interface{}) (bool, error) {
 		// We resulted in a change, because we had things to remove
 		// and presumably they're now purged.
 		changed = true
+		pm.removed = toRemove
 	}
 
 	return changed, nil
 }
 
+// GetOutputs is an optional interface method which allows the
+// module to return values to the caller - prefixed by the rule-name.
+func (pm *PackageModule) GetOutputs() map[string]string {
+
+	// Prepare a map of key->values to return
+	m := make(map[string]string)
+
+	m["installed"] = strings.Join(pm.installed, ",")
+	m["removed"] = strings.Join(pm.removed, ",")
+
+	return m
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("package", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
@@ -45,3 +45,25 @@ func TestPackageCheck(t *testing.T) {
 		t.Fatalf("got error, but not the correct one")
 	}
 }
+
+func TestPackageOutputs(t *testing.T) {
+
+	p := &PackageModule{}
+
+	// Nothing changed
+	out := p.GetOutputs()
+	if out["installed"] != "" || out["removed"] != "" {
+		t.Fatalf("unexpected outputs: %v", out)
+	}
+
+	p.installed = []string{"bash", "curl"}
+	p.removed = []string{"nano"}
+
+	out = p.GetOutputs()
+	if out["installed"] != "bash,curl" {
+		t.Fatalf("wrong installed output: %s", out["installed"])
+	}
+	if out["removed"] != "nano" {
+		t.Fatalf("wrong removed output: %s", out["removed"])
+	}
+}
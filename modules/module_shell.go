@@ -2,6 +2,7 @@ package modules
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os/exec"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
 )
 
 // ShellModule stores our state
@@ -25,6 +27,12 @@ type ShellModule struct {
 
 	// Saved copy of STDERR.
 	stderr []byte
+
+	// parse holds the format STDOUT should be parsed as, if any.
+	parse string
+
+	// Saved exit-code.
+	code int
 }
 
 // Check is part of the module-api, and checks arguments.
@@ -36,6 +44,12 @@ func (f *ShellModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("missing 'command' parameter")
 	}
 
+	// The only format we can parse is JSON.
+	parse := StringParam(args, "parse")
+	if parse != "" && parse != "json" {
+		return fmt.Errorf("parse must be 'json', got '%s'", parse)
+	}
+
 	return nil
 }
 
@@ -50,6 +64,10 @@ func (f *ShellModule) Execute(args map[string]interface{}) (bool, error) {
 		return false, fmt.Errorf("missing 'command' parameter")
 	}
 
+	// Save the format for our outputs
+	f.parse = StringParam(args, "parse")
+	f.code = 0
+
 	// process each argument
 	for _, cmd := range cmds {
 
@@ -60,6 +78,11 @@ func (f *ShellModule) Execute(args map[string]interface{}) (bool, error) {
 		if err != nil {
 			return false, err
 		}
+
+		// If we captured a failure don't run any further commands.
+		if f.code != 0 {
+			break
+		}
 	}
 
 	// shell commands always result in a change
@@ -109,7 +132,11 @@ func (f *ShellModule) executeSingle(command string, args map[string]interface{})
 	//   We found a redirection/similar then we must run via a shell.
 	//
 	if useShell {
-		bits = []string{"bash", "-c", command}
+		sh, err := f.interpreter(args)
+		if err != nil {
+			return err
+		}
+		bits = []string{sh, "-c", command}
 	}
 
 	// Show what we're executing.
@@ -133,17 +160,51 @@ func (f *ShellModule) executeSingle(command string, args map[string]interface{})
 
 	// Run the command
 	err := cmd.Run()
-	if err != nil {
-		return fmt.Errorf("error running command '%s' %s", command, err.Error())
-	}
 
 	// Save the outputs
 	f.stdout = execOut.Bytes()
 	f.stderr = execErr.Bytes()
 
+	if err != nil {
+
+		// If we're capturing the exit-code then a non-zero
+		// exit isn't an error.
+		capture := StringParam(args, "capture_status")
+		if capture == "yes" || capture == "true" {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				f.code = exitErr.ExitCode()
+				log.Printf("[DEBUG] Command '%s' exited with code %d", command, f.code)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("error running command '%s' %s", command, err.Error())
+	}
+
 	return nil
 }
 
+// interpreter returns the shell to use for commands which require one.
+//
+// This is the value of the "shell_path" parameter, if set, otherwise
+// /bin/bash, falling back to /bin/sh if bash is not installed.
+func (f *ShellModule) interpreter(args map[string]interface{}) (string, error) {
+
+	sh := StringParam(args, "shell_path")
+	if sh == "" {
+		sh = "/bin/bash"
+		if !file.Exists(sh) {
+			sh = "/bin/sh"
+		}
+	}
+
+	if !file.Exists(sh) {
+		return "", fmt.Errorf("shell interpreter '%s' does not exist", sh)
+	}
+
+	return sh, nil
+}
+
 // GetOutputs is an optional interface method which allows the
 // module to return values to the caller - prefixed by the rule-name.
 func (f *ShellModule) GetOutputs() map[string]string {
@@ -151,13 +212,78 @@ func (f *ShellModule) GetOutputs() map[string]string {
 	// Prepare a map of key->values to return
 	m := make(map[string]string)
 
+	// Flatten any JSON output into dotted keys.
+	if f.parse == "json" {
+
+		dec := json.NewDecoder(bytes.NewReader(f.stdout))
+		dec.UseNumber()
+
+		var obj interface{}
+		err := dec.Decode(&obj)
+		if err != nil {
+			log.Printf("[DEBUG] Failed to parse output as JSON: %s", err)
+		} else {
+			flattenJSON("", obj, m)
+		}
+	}
+
 	// Populate with information from our execution.
 	m["stdout"] = strings.TrimSpace(string(f.stdout))
 	m["stderr"] = strings.TrimSpace(string(f.stderr))
+	m["code"] = fmt.Sprintf("%d", f.code)
+
+	// Summarize the lines of output.
+	lines := []string{}
+	if m["stdout"] != "" {
+		lines = strings.Split(m["stdout"], "\n")
+	}
+	m["lines"] = fmt.Sprintf("%d", len(lines))
+	m["last_line"] = ""
+	if len(lines) > 0 {
+		m["last_line"] = strings.TrimSpace(lines[len(lines)-1])
+	}
 
 	return m
 }
 
+// flattenJSON stores the given JSON value in the map, with nested
+// objects and arrays using dotted keys such as "memory.total" or
+// "disks.0".
+func flattenJSON(prefix string, obj interface{}, m map[string]string) {
+
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch val := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			flattenJSON(join(k), v, m)
+		}
+	case []interface{}:
+		for i, v := range val {
+			flattenJSON(join(fmt.Sprintf("%d", i)), v, m)
+		}
+	case nil:
+		if prefix != "" {
+			m[prefix] = ""
+		}
+	default:
+		if prefix != "" {
+			m[prefix] = fmt.Sprintf("%v", val)
+		}
+	}
+}
+
+// KnownParams is an optional interface method which returns the names
+// of the parameters we understand.
+func (f *ShellModule) KnownParams() []string {
+	return []string{"capture_status", "command", "parse", "shell", "shell_path"}
+}
+
 // init is used to dynamically register our module.
 func init() {
 	Register("shell", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
@@ -97,3 +97,172 @@ func TestShell(t *testing.T) {
 		t.Fatalf("Didn't expect to see changed result")
 	}
 }
+
+func TestShellParseJSON(t *testing.T) {
+
+	s := &ShellModule{}
+
+	// Bogus format
+	args := make(map[string]interface{})
+	args["command"] = "true"
+	args["parse"] = "yaml"
+	err := s.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus parse format")
+	}
+
+	args["parse"] = "json"
+	args["shell"] = "true"
+	args["command"] = `echo '{"memory": {"total": 1024, "free": null}, "disks": ["sda", "sdb"], "virtual": true}'`
+
+	err = s.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	out := s.GetOutputs()
+
+	expected := map[string]string{
+		"memory.total": "1024",
+		"memory.free":  "",
+		"disks.0":      "sda",
+		"disks.1":      "sdb",
+		"virtual":      "true",
+	}
+	for key, val := range expected {
+		if out[key] != val {
+			t.Fatalf("wrong value for %s: '%s' != '%s'", key, out[key], val)
+		}
+	}
+	if !strings.HasPrefix(out["stdout"], "{") {
+		t.Fatalf("raw stdout missing: %s", out["stdout"])
+	}
+
+	// Invalid JSON just gives us the raw output.
+	args["command"] = "echo not json"
+	_, err = s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	out = s.GetOutputs()
+	if len(out) != 5 || out["stdout"] != "not json" {
+		t.Fatalf("unexpected outputs: %v", out)
+	}
+}
+
+func TestShellLines(t *testing.T) {
+
+	s := &ShellModule{}
+
+	type TestCase struct {
+		Command  string
+		Lines    string
+		LastLine string
+	}
+
+	tests := []TestCase{
+		{Command: "printf 'one\\ntwo\\nthree\\n'", Lines: "3", LastLine: "three"},
+		{Command: "echo single", Lines: "1", LastLine: "single"},
+		{Command: "true", Lines: "0", LastLine: ""},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["command"] = test.Command
+		args["shell"] = "true"
+
+		_, err := s.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		out := s.GetOutputs()
+		if out["lines"] != test.Lines {
+			t.Fatalf("wrong line count for %s: %s", test.Command, out["lines"])
+		}
+		if out["last_line"] != test.LastLine {
+			t.Fatalf("wrong last line for %s: %s", test.Command, out["last_line"])
+		}
+	}
+}
+
+func TestShellCaptureStatus(t *testing.T) {
+
+	s := &ShellModule{}
+
+	// Without capture a failure is an error
+	args := make(map[string]interface{})
+	args["command"] = "false"
+
+	_, err := s.Execute(args)
+	if err == nil {
+		t.Fatalf("expected error from failing command")
+	}
+
+	// With capture we get the exit-code instead
+	args["capture_status"] = "true"
+	args["command"] = []string{"true", "exit 3", "touch /this/is/never/run"}
+	args["shell"] = "true"
+
+	changed, err := s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expected change")
+	}
+	if s.GetOutputs()["code"] != "3" {
+		t.Fatalf("wrong exit code: %s", s.GetOutputs()["code"])
+	}
+
+	// Success is zero
+	args["command"] = "true"
+	_, err = s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.GetOutputs()["code"] != "0" {
+		t.Fatalf("wrong exit code: %s", s.GetOutputs()["code"])
+	}
+}
+
+func TestShellInterpreter(t *testing.T) {
+
+	s := &ShellModule{}
+
+	args := make(map[string]interface{})
+	args["command"] = "echo $0"
+	args["shell"] = "true"
+	args["shell_path"] = "/bin/sh"
+
+	_, err := s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.GetOutputs()["stdout"] != "/bin/sh" {
+		t.Fatalf("wrong interpreter used: %s", s.GetOutputs()["stdout"])
+	}
+
+	// A missing interpreter is an error
+	args["shell_path"] = "/this/does/not/exist"
+	_, err = s.Execute(args)
+	if err == nil {
+		t.Fatalf("expected error with missing interpreter")
+	}
+	if !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// The default is used if we don't specify one
+	delete(args, "shell_path")
+	_, err = s.Execute(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -1,17 +1,23 @@
 package modules
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
 
-	// TODO - more?
-	_ "github.com/go-sql-driver/mysql"
+	// The drivers we support, beyond mysql which is imported above.
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -48,10 +54,21 @@ func (f *SQLModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("unknown driver: %s - valid options are %s", driver, strings.Join(drivers, ","))
 	}
 
-	// Ensure we have a dsn-name
+	// Ensure we have a dsn-name, or the parts to build one from.
 	_, ok = args["dsn"]
 	if !ok {
-		return fmt.Errorf("missing 'dsn' parameter")
+		_, ok = args["database"]
+		if !ok {
+			return fmt.Errorf("missing 'dsn' parameter, or 'database' to build one from")
+		}
+
+		// Networked databases need a host to connect to.
+		if driver != "sqlite3" {
+			_, ok = args["host"]
+			if !ok {
+				return fmt.Errorf("missing 'host' parameter")
+			}
+		}
 	}
 
 	// We must have one of "sql" or "sql_file"
@@ -68,9 +85,78 @@ func (f *SQLModule) Check(args map[string]interface{}) error {
 		return fmt.Errorf("you must specify one of 'sql' or 'sql_file'")
 	}
 
+	// The timeout is optional, but must be valid if present.
+	_, err := f.timeout(args)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// timeout returns the timeout to use when executing our SQL.
+//
+// Zero is returned if no timeout was specified.
+func (f *SQLModule) timeout(args map[string]interface{}) (time.Duration, error) {
+
+	str := StringParam(args, "timeout")
+	if str == "" {
+		return 0, nil
+	}
+
+	secs, err := strconv.Atoi(str)
+	if err != nil || secs < 1 {
+		return 0, fmt.Errorf("'timeout' must be a positive number of seconds, got '%s'", str)
+	}
+
+	return time.Duration(secs) * time.Second, nil
+}
+
+// buildDSN assembles a driver-appropriate DSN from the "host", "port",
+// "user", "password", and "database" parameters.
+func (f *SQLModule) buildDSN(driver string, args map[string]interface{}) string {
+
+	host := StringParam(args, "host")
+	port := StringParam(args, "port")
+	user := StringParam(args, "user")
+	pass := StringParam(args, "password")
+	database := StringParam(args, "database")
+
+	switch driver {
+	case "mysql":
+		if port == "" {
+			port = "3306"
+		}
+		cfg := mysql.NewConfig()
+		cfg.User = user
+		cfg.Passwd = pass
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(host, port)
+		cfg.DBName = database
+		return cfg.FormatDSN()
+
+	case "postgres":
+		if port == "" {
+			port = "5432"
+		}
+		u := url.URL{
+			Scheme: "postgres",
+			Host:   net.JoinHostPort(host, port),
+			Path:   "/" + database,
+		}
+		if user != "" {
+			u.User = url.UserPassword(user, pass)
+			if pass == "" {
+				u.User = url.User(user)
+			}
+		}
+		return u.String()
+	}
+
+	// For SQLite the database is the path to the file.
+	return database
+}
+
 // Execute is part of the module-api, and is invoked to run a rule.
 func (f *SQLModule) Execute(args map[string]interface{}) (bool, error) {
 
@@ -78,6 +164,11 @@ func (f *SQLModule) Execute(args map[string]interface{}) (bool, error) {
 	dsn := StringParam(args, "dsn")
 	driver := StringParam(args, "driver")
 
+	// Build the DSN, if we weren't given one.
+	if dsn == "" {
+		dsn = f.buildDSN(driver, args)
+	}
+
 	// One of these will be valid
 	sqlText := StringParam(args, "sql")
 	sqlFile := StringParam(args, "file")
@@ -91,6 +182,9 @@ func (f *SQLModule) Execute(args map[string]interface{}) (bool, error) {
 	// Avoid leaking the handle.
 	defer db.Close()
 
+	// The handle is only used once, so don't keep connections around.
+	db.SetConnMaxLifetime(30 * time.Second)
+
 	// We're either running a query with a literal string,
 	// or reading from a file.
 	if sqlFile != "" {
@@ -104,9 +198,24 @@ func (f *SQLModule) Execute(args map[string]interface{}) (bool, error) {
 		sqlText = string(data)
 	}
 
+	// Setup a timeout, if we should.
+	ctx := context.Background()
+	timeout, err := f.timeout(args)
+	if err != nil {
+		return false, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// Now actually run the SQL
-	res, execErr := db.Exec(sqlText)
+	res, execErr := db.ExecContext(ctx, sqlText)
 	if execErr != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return false, fmt.Errorf("timed out after %s running '%s'", timeout, sqlText)
+		}
 		return false, execErr
 	}
 
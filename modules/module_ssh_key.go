@@ -0,0 +1,179 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// SSHKeyModule stores our state.
+type SSHKeyModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// Check is part of the module-api, and checks arguments.
+func (s *SSHKeyModule) Check(args map[string]interface{}) error {
+
+	// Required keys for this module
+	required := []string{"user", "key"}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	if keyBody(StringParam(args, "key")) == "" {
+		return fmt.Errorf("'key' doesn't look like an SSH public key")
+	}
+
+	state := StringParam(args, "state")
+	if state != "" && state != "present" && state != "absent" {
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (s *SSHKeyModule) Execute(args map[string]interface{}) (bool, error) {
+
+	owner := StringParam(args, "user")
+	key := strings.TrimSpace(StringParam(args, "key"))
+	body := keyBody(key)
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	// Find the file we're going to change.
+	path := StringParam(args, "path")
+	if path == "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return false, err
+		}
+		path = filepath.Join(u.HomeDir, ".ssh", "authorized_keys")
+	}
+
+	// Read the existing keys, if any.
+	var lines []string
+	if file.Exists(path) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		if len(data) > 0 {
+			lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		}
+	} else if state == "absent" {
+		return false, nil
+	}
+
+	// Update the keys, ignoring any comments.
+	var out []string
+	found := false
+	for _, line := range lines {
+		if keyBody(line) == body {
+			found = true
+			if state == "absent" {
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+
+	if state == "present" {
+		if found {
+			return false, nil
+		}
+		out = append(out, key)
+	} else if !found {
+		return false, nil
+	}
+
+	// Ensure the directory exists, if we're creating the file.
+	dir := filepath.Dir(path)
+	if !file.Exists(dir) {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return false, err
+		}
+		_, err = file.ChangeOwner(dir, owner)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	content := ""
+	if len(out) > 0 {
+		content = strings.Join(out, "\n") + "\n"
+	}
+
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		return false, err
+	}
+
+	// sshd is fussy about permissions.
+	_, err = file.ChangeMode(path, "0600")
+	if err != nil {
+		return false, err
+	}
+
+	_, err = file.ChangeOwner(path, owner)
+	return true, err
+}
+
+// keyBody returns the base64-encoded body of the given public key,
+// ignoring any options and comment which might surround it.
+//
+// An empty string is returned for lines which don't contain a key.
+func keyBody(line string) string {
+
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	fields := strings.Fields(line)
+	for i, field := range fields[:len(fields)-1] {
+		if strings.HasPrefix(field, "ssh-") ||
+			strings.HasPrefix(field, "ecdsa-") ||
+			strings.HasPrefix(field, "sk-") {
+			return fields[i+1]
+		}
+	}
+
+	return ""
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("authorized_keys", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &SSHKeyModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHKeyCheck(t *testing.T) {
+
+	s := &SSHKeyModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'user'
+	err := s.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing user")
+	}
+	if !strings.Contains(err.Error(), "missing 'user'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'key'
+	args["user"] = "steve"
+	err = s.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing key")
+	}
+	if !strings.Contains(err.Error(), "missing 'key'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Bogus key
+	args["key"] = "steve"
+	err = s.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus key")
+	}
+
+	// Bogus state
+	args["key"] = "ssh-ed25519 AAAAC3Nza steve@host"
+	args["state"] = "bogus"
+	err = s.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+
+	args["state"] = "absent"
+	err = s.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestSSHKeyBody(t *testing.T) {
+
+	tests := map[string]string{
+		"ssh-ed25519 AAAAC3Nza steve@host":                     "AAAAC3Nza",
+		"ssh-rsa AAAAB3Nza":                                    "AAAAB3Nza",
+		`no-pty,command="/bin/true" ssh-rsa AAAAB3Nza comment`: "AAAAB3Nza",
+		"ecdsa-sha2-nistp256 AAAAE2Vj":                         "AAAAE2Vj",
+		"# ssh-rsa AAAAB3Nza":                                  "",
+		"":                                                     "",
+		"ssh-rsa":                                              "",
+	}
+
+	for input, expected := range tests {
+		out := keyBody(input)
+		if out != expected {
+			t.Fatalf("wrong key body for '%s': %s != %s", input, out, expected)
+		}
+	}
+}
+
+func TestSSHKeyExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	me, err := user.Current()
+	if err != nil {
+		t.Skip("failed to find the current user")
+	}
+
+	path := filepath.Join(dir, ".ssh", "authorized_keys")
+
+	type TestCase struct {
+		Key     string
+		State   string
+		Changed bool
+		Output  string
+	}
+
+	tests := []TestCase{
+		// the file and directory are created
+		{Key: "ssh-ed25519 AAAAone first", Changed: true,
+			Output: "ssh-ed25519 AAAAone first\n"},
+
+		// a second key is appended
+		{Key: "ssh-rsa AAAAtwo second", Changed: true,
+			Output: "ssh-ed25519 AAAAone first\nssh-rsa AAAAtwo second\n"},
+
+		// the comment is ignored
+		{Key: "ssh-ed25519 AAAAone different comment", Changed: false},
+
+		// removal
+		{Key: "ssh-ed25519 AAAAone", State: "absent", Changed: true,
+			Output: "ssh-rsa AAAAtwo second\n"},
+		{Key: "ssh-ed25519 AAAAone", State: "absent", Changed: false},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["user"] = me.Username
+		args["key"] = test.Key
+		args["state"] = test.State
+		args["path"] = path
+
+		s := &SSHKeyModule{}
+		changed, err := s.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+
+		if test.Output != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %s", err)
+			}
+			if string(data) != test.Output {
+				t.Fatalf("unexpected output for %v:\n%s", test, data)
+			}
+		}
+	}
+
+	// Check the permissions
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to stat directory: %s", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Fatalf("wrong directory permissions: %o", info.Mode().Perm())
+	}
+
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong file permissions: %o", info.Mode().Perm())
+	}
+}
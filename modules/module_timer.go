@@ -0,0 +1,197 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// TimerModule stores our state.
+type TimerModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+
+	// units is the directory the unit-files are written to.
+	units string
+
+	// systemctl runs systemctl with the given arguments.  It may be
+	// replaced for testing purposes.
+	systemctl func(args ...string) error
+}
+
+// Check is part of the module-api, and checks arguments.
+func (t *TimerModule) Check(args map[string]interface{}) error {
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+	if state != "present" && state != "absent" {
+		return fmt.Errorf("state must be one of 'present' or 'absent', got '%s'", state)
+	}
+
+	// Required keys for this module
+	required := []string{"name"}
+	if state == "present" {
+		required = append(required, "command", "on_calendar")
+	}
+
+	// Ensure they exist.
+	for _, key := range required {
+		_, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing '%s' parameter", key)
+		}
+
+		val := StringParam(args, key)
+		if val == "" {
+			return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+		}
+	}
+
+	// The name is used as a filename.
+	name := StringParam(args, "name")
+	if strings.ContainsAny(name, "/ ") {
+		return fmt.Errorf("invalid timer name '%s'", name)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (t *TimerModule) Execute(args map[string]interface{}) (bool, error) {
+
+	name := StringParam(args, "name")
+
+	state := StringParam(args, "state")
+	if state == "" {
+		state = "present"
+	}
+
+	service := filepath.Join(t.units, name+".service")
+	timer := filepath.Join(t.units, name+".timer")
+
+	if state == "absent" {
+		return t.remove(name, []string{timer, service})
+	}
+
+	description := StringParam(args, "description")
+	if description == "" {
+		description = "marionette timer " + name
+	}
+
+	serviceUnit := fmt.Sprintf(`[Unit]
+Description=%s
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, description, StringParam(args, "command"))
+
+	timerUnit := fmt.Sprintf(`[Unit]
+Description=%s
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, description, StringParam(args, "on_calendar"))
+
+	// Helper for writing files.
+	helper := &FileModule{cfg: t.cfg, env: t.env}
+
+	changedService, err := helper.CreateFile(service, serviceUnit)
+	if err != nil {
+		return false, err
+	}
+	changedTimer, err := helper.CreateFile(timer, timerUnit)
+	if err != nil {
+		return false, err
+	}
+
+	if !changedService && !changedTimer {
+		return false, nil
+	}
+
+	// Reload systemd and (re)start the timer.
+	err = t.run("daemon-reload")
+	if err != nil {
+		return false, err
+	}
+	err = t.run("enable", "--now", name+".timer")
+	return true, err
+}
+
+// remove stops the timer, and removes the given unit-files, reporting a
+// change if any existed.
+func (t *TimerModule) remove(name string, units []string) (bool, error) {
+
+	existing := []string{}
+	for _, path := range units {
+		if file.Exists(path) {
+			existing = append(existing, path)
+		}
+	}
+
+	if len(existing) == 0 {
+		return false, nil
+	}
+
+	err := t.run("disable", "--now", name+".timer")
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range existing {
+
+		log.Printf("[DEBUG] Removing %s", path)
+
+		err = os.Remove(path)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = t.run("daemon-reload")
+	return true, err
+}
+
+// run invokes systemctl with the given arguments.
+func (t *TimerModule) run(args ...string) error {
+
+	if t.systemctl != nil {
+		return t.systemctl(args...)
+	}
+
+	log.Printf("[DEBUG] Running systemctl %s", strings.Join(args, " "))
+
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running 'systemctl %s' %s %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("systemd_timer", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &TimerModule{
+			cfg:   cfg,
+			env:   env,
+			units: "/etc/systemd/system",
+		}
+	})
+}
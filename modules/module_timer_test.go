@@ -0,0 +1,155 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/marionette/file"
+)
+
+func TestTimerCheck(t *testing.T) {
+
+	tm := &TimerModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'name'
+	err := tm.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing name")
+	}
+	if !strings.Contains(err.Error(), "missing 'name'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'command'
+	args["name"] = "backup"
+	err = tm.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing command")
+	}
+	if !strings.Contains(err.Error(), "missing 'command'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Missing 'on_calendar'
+	args["command"] = "/usr/local/bin/backup"
+	err = tm.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing on_calendar")
+	}
+	if !strings.Contains(err.Error(), "missing 'on_calendar'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	args["on_calendar"] = "daily"
+	err = tm.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Bogus name
+	args["name"] = "../backup"
+	err = tm.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus name")
+	}
+
+	// Bogus state
+	args["name"] = "backup"
+	args["state"] = "bogus"
+	err = tm.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus state")
+	}
+
+	// Removal only needs a name
+	args = make(map[string]interface{})
+	args["name"] = "backup"
+	args["state"] = "absent"
+	err = tm.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestTimerExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// The systemctl commands we ran
+	var ran []string
+
+	tm := &TimerModule{units: dir}
+	tm.systemctl = func(args ...string) error {
+		ran = append(ran, strings.Join(args, " "))
+		return nil
+	}
+
+	type TestCase struct {
+		Calendar string
+		State    string
+		Changed  bool
+		Commands string
+	}
+
+	tests := []TestCase{
+		{Calendar: "daily", Changed: true, Commands: "daemon-reload,enable --now backup.timer"},
+		{Calendar: "daily", Changed: false},
+		{Calendar: "weekly", Changed: true, Commands: "daemon-reload,enable --now backup.timer"},
+		{State: "absent", Changed: true, Commands: "disable --now backup.timer,daemon-reload"},
+		{State: "absent", Changed: false},
+	}
+
+	for _, test := range tests {
+
+		ran = []string{}
+
+		args := make(map[string]interface{})
+		args["name"] = "backup"
+		args["command"] = "/usr/local/bin/backup"
+		args["on_calendar"] = test.Calendar
+		args["state"] = test.State
+
+		changed, err := tm.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+		if strings.Join(ran, ",") != test.Commands {
+			t.Fatalf("unexpected commands for %v: %v", test, ran)
+		}
+
+		if test.State == "" {
+			data, err := ioutil.ReadFile(filepath.Join(dir, "backup.timer"))
+			if err != nil {
+				t.Fatalf("failed to read timer: %s", err)
+			}
+			if !strings.Contains(string(data), "OnCalendar="+test.Calendar+"\n") {
+				t.Fatalf("timer has the wrong schedule:\n%s", data)
+			}
+
+			data, err = ioutil.ReadFile(filepath.Join(dir, "backup.service"))
+			if err != nil {
+				t.Fatalf("failed to read service: %s", err)
+			}
+			if !strings.Contains(string(data), "ExecStart=/usr/local/bin/backup\n") {
+				t.Fatalf("service has the wrong command:\n%s", data)
+			}
+		}
+	}
+
+	if file.Exists(filepath.Join(dir, "backup.timer")) || file.Exists(filepath.Join(dir, "backup.service")) {
+		t.Fatalf("unit files weren't removed")
+	}
+}
@@ -0,0 +1,158 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// TimezoneModule stores our state.
+type TimezoneModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+
+	// localtime is the symlink which points to the current zone.
+	localtime string
+
+	// zoneinfo is the directory containing the zone definitions.
+	zoneinfo string
+
+	// timedatectl is the command to use to change the zone, if
+	// it is available.
+	timedatectl string
+}
+
+// Check is part of the module-api, and checks arguments.
+func (t *TimezoneModule) Check(args map[string]interface{}) error {
+
+	// Ensure we have a name.
+	_, ok := args["name"]
+	if !ok {
+		return fmt.Errorf("missing 'name' parameter")
+	}
+
+	name := StringParam(args, "name")
+	if name == "" {
+		return fmt.Errorf("parameter 'name' wasn't a simple string")
+	}
+
+	// Avoid escaping from the zoneinfo directory.
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid timezone '%s'", name)
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (t *TimezoneModule) Execute(args map[string]interface{}) (bool, error) {
+
+	name := StringParam(args, "name")
+
+	// Ensure the zone exists.
+	zone := filepath.Join(t.zoneinfo, name)
+	if !file.Exists(zone) {
+		return false, fmt.Errorf("unknown timezone '%s'", name)
+	}
+
+	// Is the zone already correct?
+	if t.current() == name {
+		return false, nil
+	}
+
+	// Prefer timedatectl, if we have it.
+	if t.timedatectl != "" {
+
+		log.Printf("[DEBUG] Running %s set-timezone %s", t.timedatectl, name)
+
+		out, err := exec.Command(t.timedatectl, "set-timezone", name).CombinedOutput()
+		if err != nil {
+			return false, fmt.Errorf("failed to set timezone: %s %s", err, strings.TrimSpace(string(out)))
+		}
+		return true, nil
+	}
+
+	// Otherwise update the symlink.
+	log.Printf("[DEBUG] Linking %s to %s", t.localtime, zone)
+
+	tmp := t.localtime + ".marionette"
+	os.Remove(tmp)
+
+	err := os.Symlink(zone, tmp)
+	if err != nil {
+		return false, err
+	}
+
+	err = os.Rename(tmp, t.localtime)
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// current returns the name of the current timezone, or the empty string
+// if it cannot be determined.
+func (t *TimezoneModule) current() string {
+
+	// Look at the symlink.
+	dst, err := os.Readlink(t.localtime)
+	if err == nil {
+
+		// The link might be relative.
+		if !filepath.IsAbs(dst) {
+			dst = filepath.Join(filepath.Dir(t.localtime), dst)
+		}
+
+		rel, err := filepath.Rel(t.zoneinfo, filepath.Clean(dst))
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+
+	// Otherwise ask timedatectl.
+	if t.timedatectl != "" {
+		out, err := exec.Command(t.timedatectl, "show", "--property=Timezone", "--value").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+
+	// Finally try /etc/timezone
+	data, err := ioutil.ReadFile("/etc/timezone")
+	if err == nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	return ""
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("timezone", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+
+		// Find timedatectl, if it is available
+		timedatectl, _ := exec.LookPath("timedatectl")
+
+		return &TimezoneModule{
+			cfg:         cfg,
+			env:         env,
+			localtime:   "/etc/localtime",
+			zoneinfo:    "/usr/share/zoneinfo",
+			timedatectl: timedatectl,
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTimezoneCheck(t *testing.T) {
+
+	tz := &TimezoneModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing 'name'
+	err := tz.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing name")
+	}
+	if !strings.Contains(err.Error(), "missing 'name'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Bogus names
+	for _, name := range []string{"/etc/passwd", "../../etc/passwd"} {
+		args["name"] = name
+		err = tz.Check(args)
+		if err == nil {
+			t.Fatalf("expected error due to bogus name %s", name)
+		}
+	}
+
+	args["name"] = "Europe/London"
+	err = tz.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestTimezoneExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a fake zoneinfo directory
+	zoneinfo := filepath.Join(dir, "zoneinfo")
+	for _, zone := range []string{"Europe/London", "Europe/Helsinki"} {
+		path := filepath.Join(zoneinfo, zone)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		err = ioutil.WriteFile(path, []byte(zone), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+
+	localtime := filepath.Join(dir, "localtime")
+	err = os.Symlink(filepath.Join(zoneinfo, "Europe/London"), localtime)
+	if err != nil {
+		t.Fatalf("failed to create symlink: %s", err)
+	}
+
+	tz := &TimezoneModule{localtime: localtime, zoneinfo: zoneinfo}
+
+	type TestCase struct {
+		Name    string
+		Changed bool
+		Error   bool
+	}
+
+	tests := []TestCase{
+		{Name: "Europe/London", Changed: false},
+		{Name: "Europe/Helsinki", Changed: true},
+		{Name: "Europe/Helsinki", Changed: false},
+		{Name: "Mars/Olympus", Error: true},
+	}
+
+	for _, test := range tests {
+
+		args := make(map[string]interface{})
+		args["name"] = test.Name
+
+		changed, err := tz.Execute(args)
+		if test.Error {
+			if err == nil {
+				t.Fatalf("expected error for %s", test.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if changed != test.Changed {
+			t.Fatalf("unexpected change result for %v: %t", test, changed)
+		}
+		if tz.current() != test.Name {
+			t.Fatalf("wrong timezone, got %s", tz.current())
+		}
+	}
+}
@@ -7,6 +7,8 @@ package modules
 import (
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 
 	mcfg "github.com/skx/marionette/config"
 	"github.com/skx/marionette/environment"
@@ -54,6 +56,27 @@ func (g *UserModule) Check(args map[string]interface{}) error {
 
 	}
 
+	// Ensure any groups have decent characters
+	for _, grp := range ArrayCastParam(args, "groups") {
+		if !g.reg.MatchString(grp) {
+			return fmt.Errorf("group '%s' failed validation", grp)
+		}
+	}
+
+	// Ensure the UID, if present, is numeric
+	uid := StringParam(args, "uid")
+	if uid != "" {
+		if _, err := strconv.Atoi(uid); err != nil {
+			return fmt.Errorf("parameter 'uid' must be numeric")
+		}
+	}
+
+	// Ensure the password-hash, if present, can't corrupt
+	// the shadow file.
+	if strings.ContainsAny(StringParam(args, "password"), ":\n") {
+		return fmt.Errorf("parameter 'password' failed validation")
+	}
+
 	// Ensure state is one of "present"/"absent"
 	state := StringParam(args, "state")
 	if state == "absent" {
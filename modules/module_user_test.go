@@ -0,0 +1,54 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserCheck(t *testing.T) {
+
+	u := Lookup("user", nil, nil)
+
+	type TestCase struct {
+		Args  map[string]interface{}
+		Error string
+	}
+
+	tests := []TestCase{
+		{Args: map[string]interface{}{"state": "present"},
+			Error: "missing 'login'"},
+		{Args: map[string]interface{}{"login": "steve"},
+			Error: "missing 'state'"},
+		{Args: map[string]interface{}{"login": "steve", "state": "maybe"},
+			Error: "state must be one of"},
+		{Args: map[string]interface{}{"login": "steve;id", "state": "present"},
+			Error: "parameter 'login' failed validation"},
+		{Args: map[string]interface{}{"login": "steve", "state": "present", "groups": []string{"sudo", "a b"}},
+			Error: "group 'a b' failed validation"},
+		{Args: map[string]interface{}{"login": "steve", "state": "present", "uid": "one"},
+			Error: "parameter 'uid' must be numeric"},
+		{Args: map[string]interface{}{"login": "steve", "state": "present", "password": "$6$salt:hash"},
+			Error: "parameter 'password' failed validation"},
+		{Args: map[string]interface{}{"login": "steve", "state": "present", "uid": "1000", "groups": []string{"sudo", "adm"}, "password": "$6$salt$hash"}},
+		{Args: map[string]interface{}{"login": "steve", "state": "absent"}},
+	}
+
+	for _, test := range tests {
+
+		err := u.Check(test.Args)
+
+		if test.Error == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for %v: %s", test.Args, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("expected an error for %v", test.Args)
+		}
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Fatalf("got error - but wrong one : %s", err)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"os/exec"
 	"os/user"
+	"sort"
+	"strings"
 	"syscall"
 )
 
@@ -24,8 +26,19 @@ func (g *UserModule) Execute(args map[string]interface{}) (bool, error) {
 		if state == "present" {
 
 			// We're supposed to create the user, but it
-			// already exists.  Do nothing.
-			return false, nil
+			// already exists.  Ensure the groups and password
+			// are correct.
+			changed, err := g.updateGroups(args)
+			if err != nil {
+				return false, err
+			}
+
+			pChanged, err := g.updatePassword(args)
+			if err != nil {
+				return false, err
+			}
+
+			return changed || pChanged, nil
 		}
 		if state == "absent" {
 
@@ -75,35 +88,187 @@ func (g *UserModule) createUser(args map[string]interface{}) error {
 	}
 
 	// The user-creation command
-	cmdArgs := []string{"useradd", "--shell", shell, login}
+	cmdArgs := []string{"useradd", "--shell", shell}
 
-	// do we need to enhance our permissions?
-	privs := StringParam(args, "elevate")
-	if privs != "" {
-		cmdArgs = append([]string{privs}, cmdArgs...)
+	// Specific UID?
+	uid := StringParam(args, "uid")
+	if uid != "" {
+		cmdArgs = append(cmdArgs, "-u", uid)
+	}
+
+	// Specific home directory?
+	home := StringParam(args, "home")
+	if home != "" {
+		cmdArgs = append(cmdArgs, "-m", "-d", home)
+	}
+
+	// System user?
+	system := StringParam(args, "system")
+	if system == "yes" || system == "true" {
+		cmdArgs = append(cmdArgs, "--system")
+	}
+
+	// Supplementary groups?
+	groups := ArrayCastParam(args, "groups")
+	if len(groups) > 0 {
+		cmdArgs = append(cmdArgs, "-G", strings.Join(groups, ","))
+	}
+
+	// Pre-hashed password?
+	password := StringParam(args, "password")
+	if password != "" {
+		cmdArgs = append(cmdArgs, "-p", password)
+	}
+
+	cmdArgs = append(cmdArgs, login)
+
+	return g.run(args, cmdArgs)
+}
+
+// updatePassword ensures that an existing user has the password-hash
+// which was specified, if one was.
+func (g *UserModule) updatePassword(args map[string]interface{}) (bool, error) {
+
+	// No password specified?  Then there's nothing to do.
+	password := StringParam(args, "password")
+	if password == "" {
+		return false, nil
+	}
+
+	login := StringParam(args, "login")
+
+	current, err := g.currentPassword(args, login)
+	if err != nil {
+		return false, err
 	}
 
-	// Show what we're doing
+	if current == password {
+		return false, nil
+	}
+
+	log.Printf("[DEBUG] Updating password of %s", login)
+
+	err = g.run(args, []string{"usermod", "-p", password, login})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// currentPassword returns the password-hash of the given user, from
+// the shadow database.
+//
+// The shadow database is usually only readable by root, so the lookup
+// is made via `getent`, prefixed with any privilege helper.
+func (g *UserModule) currentPassword(args map[string]interface{}, login string) (string, error) {
+
+	cmdArgs := g.elevated(args, []string{"getent", "shadow", login})
+
 	log.Printf("[DEBUG] Running %s", cmdArgs)
 
-	// Run it
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-	if err := cmd.Start(); err != nil {
-		return err
+	out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup password of %s: %s", login, err)
 	}
 
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
+	fields := strings.Split(strings.TrimSpace(string(out)), ":")
+	if len(fields) < 2 || fields[0] != login {
+		return "", fmt.Errorf("user %s not found in the shadow database", login)
+	}
 
-		if exiterr, ok := err.(*exec.ExitError); ok {
+	return fields[1], nil
+}
 
-			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-				return fmt.Errorf("exit code was %d", status.ExitStatus())
-			}
+// updateGroups ensures that an existing user is a member of exactly
+// the supplementary groups which were specified, if any were.
+func (g *UserModule) updateGroups(args map[string]interface{}) (bool, error) {
+
+	// No groups specified?  Then there's nothing to do.
+	groups := ArrayCastParam(args, "groups")
+	if len(groups) < 1 {
+		return false, nil
+	}
+
+	login := StringParam(args, "login")
+
+	current, primary, err := g.currentGroups(login)
+	if err != nil {
+		return false, err
+	}
+
+	// Are the groups identical?
+	if sameGroups(current, groups, primary) {
+		return false, nil
+	}
+
+	log.Printf("[DEBUG] Updating groups of %s from %s to %s", login, current, groups)
+
+	// Update the groups
+	err = g.run(args, []string{"usermod", "-G", strings.Join(groups, ","), login})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sameGroups returns true if the current supplementary groups of a user
+// match those which are wanted.
+//
+// The primary group is ignored if it is wanted, since it is never
+// included in the current groups.
+func sameGroups(current []string, wanted []string, primary string) bool {
+
+	a := append([]string{}, current...)
+	sort.Strings(a)
+
+	var b []string
+	for _, grp := range wanted {
+		if grp != primary {
+			b = append(b, grp)
 		}
 	}
+	sort.Strings(b)
 
-	return nil
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+// currentGroups returns the names of the supplementary groups the
+// given user is a member of, along with the name of their primary group.
+//
+// The primary group of the user is not included in the list.
+func (g *UserModule) currentGroups(login string) ([]string, string, error) {
+
+	u, err := user.Lookup(login)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, "", err
+	}
+
+	primary := ""
+	if grp, err := user.LookupGroupId(u.Gid); err == nil {
+		primary = grp.Name
+	}
+
+	var names []string
+	for _, id := range ids {
+
+		// Skip the primary group
+		if id == u.Gid {
+			continue
+		}
+
+		grp, err := user.LookupGroupId(id)
+		if err != nil {
+			return nil, "", err
+		}
+		names = append(names, grp.Name)
+	}
+
+	return names, primary, nil
 }
 
 // removeUser removes the local user
@@ -114,14 +279,17 @@ func (g *UserModule) removeUser(args map[string]interface{}) error {
 	// The user-removal command
 	cmdArgs := []string{"userdel", login}
 
-	// do we need to enhance our permissions?
-	privs := StringParam(args, "elevate")
-	if privs != "" {
-		cmdArgs = append([]string{privs}, cmdArgs...)
-	}
+	return g.run(args, cmdArgs)
+}
 
-	// Show what we're doing
-	log.Printf("[DEBUG] Running %s", cmdArgs)
+// run executes the given command, prefixing it with any privilege
+// helper, and returns an error if it fails.
+func (g *UserModule) run(args map[string]interface{}, cmdArgs []string) error {
+
+	cmdArgs = g.elevated(args, cmdArgs)
+
+	// Show what we're doing, without showing any password-hash.
+	log.Printf("[DEBUG] Running %s", redactPassword(cmdArgs))
 
 	// Run it
 	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
@@ -142,3 +310,27 @@ func (g *UserModule) removeUser(args map[string]interface{}) error {
 
 	return nil
 }
+
+// elevated returns the given command, prefixed with the privilege
+// helper, if one was specified.
+func (g *UserModule) elevated(args map[string]interface{}, cmdArgs []string) []string {
+
+	privs := StringParam(args, "elevate")
+	if privs != "" {
+		cmdArgs = append([]string{privs}, cmdArgs...)
+	}
+	return cmdArgs
+}
+
+// redactPassword returns a copy of the given command, with any
+// password-hash replaced, so that it may be logged safely.
+func redactPassword(cmdArgs []string) []string {
+	out := make([]string, len(cmdArgs))
+	for i, arg := range cmdArgs {
+		if i > 0 && cmdArgs[i-1] == "-p" {
+			arg = "********"
+		}
+		out[i] = arg
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+//go:build !darwin && !windows
+
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUserPassword(t *testing.T) {
+
+	// Create a fake privilege helper, which records the command it
+	// was asked to run, and outputs a shadow entry for "steve".
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	record := filepath.Join(dir, "command")
+	helper := filepath.Join(dir, "elevate")
+	script := `#!/bin/sh
+echo "$@" > ` + record + `
+if [ "$3" = "steve" ]; then
+  echo 'steve:$6$salt$hash:19000:0:99999:7:::'
+else
+  exit 2
+fi
+`
+	err = ioutil.WriteFile(helper, []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("error writing helper: %s", err)
+	}
+
+	args := map[string]interface{}{"elevate": helper}
+
+	u := &UserModule{}
+
+	hash, err := u.currentPassword(args, "steve")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hash != "$6$salt$hash" {
+		t.Fatalf("wrong hash: %s", hash)
+	}
+
+	// The lookup should have been made via the helper
+	cmd, err := ioutil.ReadFile(record)
+	if err != nil {
+		t.Fatalf("helper wasn't invoked: %s", err)
+	}
+	if strings.TrimSpace(string(cmd)) != "getent shadow steve" {
+		t.Fatalf("helper invoked with the wrong command: %s", cmd)
+	}
+
+	_, err = u.currentPassword(args, "bob")
+	if err == nil {
+		t.Fatalf("expected an error for a missing user")
+	}
+
+	// Password-hashes are never logged
+	out := redactPassword([]string{"usermod", "-p", "$6$salt$hash", "steve"})
+	if strings.Contains(strings.Join(out, " "), "hash") {
+		t.Fatalf("password-hash was not redacted: %s", out)
+	}
+}
+
+func TestUserSameGroups(t *testing.T) {
+
+	type TestCase struct {
+		Current []string
+		Wanted  []string
+		Primary string
+		Same    bool
+	}
+
+	tests := []TestCase{
+		{Current: []string{"adm", "sudo"}, Wanted: []string{"sudo", "adm"}, Primary: "steve", Same: true},
+		{Current: []string{"adm", "sudo"}, Wanted: []string{"sudo"}, Primary: "steve", Same: false},
+		{Current: []string{"adm"}, Wanted: []string{"sudo", "adm"}, Primary: "steve", Same: false},
+
+		// The primary group may be listed, but never appears
+		// in the current groups.
+		{Current: []string{"adm", "sudo"}, Wanted: []string{"steve", "sudo", "adm"}, Primary: "steve", Same: true},
+		{Current: []string{}, Wanted: []string{"steve"}, Primary: "steve", Same: true},
+		{Current: []string{"adm"}, Wanted: []string{"steve"}, Primary: "steve", Same: false},
+	}
+
+	for _, test := range tests {
+		out := sameGroups(test.Current, test.Wanted, test.Primary)
+		if out != test.Same {
+			t.Fatalf("expected %v for %v vs %v, got %v", test.Same, test.Current, test.Wanted, out)
+		}
+	}
+}
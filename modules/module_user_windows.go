@@ -0,0 +1,97 @@
+//go:build windows
+
+package modules
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+)
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (g *UserModule) Execute(args map[string]interface{}) (bool, error) {
+
+	// User/State - we've already confirmed these are valid
+	// in our check function.
+	login := StringParam(args, "login")
+	state := StringParam(args, "state")
+
+	// Does the user exist?
+	if g.userExists(login) {
+
+		if state == "present" {
+
+			// We're supposed to create the user, but it
+			// already exists.  Do nothing.
+			return false, nil
+		}
+		if state == "absent" {
+
+			// remove the user
+			err := g.removeUser(args)
+			return true, err
+		}
+	}
+
+	if state == "absent" {
+
+		// The user is not present, and we're supposed to remove
+		// it.  Do nothing.
+		return false, nil
+	}
+
+	// Create the user
+	ret := g.createUser(args)
+
+	// error?
+	if ret != nil {
+		return false, ret
+	}
+
+	return true, nil
+}
+
+// userExists tests if the given user exists.
+func (g *UserModule) userExists(login string) bool {
+
+	// "net user $login" succeeds only if the user exists.
+	cmd := exec.Command("net", "user", login)
+	return cmd.Run() == nil
+}
+
+// createUser creates a local user.
+//
+// NOTE: None of the optional parameters are supported on Windows.
+func (g *UserModule) createUser(args map[string]interface{}) error {
+
+	login := StringParam(args, "login")
+
+	return g.run([]string{"net", "user", login, "/add"})
+}
+
+// removeUser removes the local user
+func (g *UserModule) removeUser(args map[string]interface{}) error {
+
+	login := StringParam(args, "login")
+
+	return g.run([]string{"net", "user", login, "/delete"})
+}
+
+// run executes the given command, and returns an error if it fails.
+func (g *UserModule) run(cmdArgs []string) error {
+
+	// Show what we're doing
+	log.Printf("[DEBUG] Running %s", cmdArgs)
+
+	// Run it
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if exiterr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("exit code was %d: %s", exiterr.ExitCode(), out)
+		}
+		return err
+	}
+
+	return nil
+}
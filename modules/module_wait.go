@@ -0,0 +1,156 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skx/marionette/config"
+	"github.com/skx/marionette/environment"
+	"github.com/skx/marionette/file"
+)
+
+// WaitModule stores our state.
+type WaitModule struct {
+
+	// cfg contains our configuration object.
+	cfg *config.Config
+
+	// env holds our environment
+	env *environment.Environment
+}
+
+// waitTargets are the keys which describe what we wait for.
+var waitTargets = []string{"port", "path", "url"}
+
+// Check is part of the module-api, and checks arguments.
+func (w *WaitModule) Check(args map[string]interface{}) error {
+
+	// We need exactly one thing to wait for.
+	count := 0
+	for _, key := range waitTargets {
+		_, ok := args[key]
+		if ok {
+			count++
+
+			if StringParam(args, key) == "" {
+				return fmt.Errorf("parameter '%s' wasn't a simple string", key)
+			}
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("missing 'port', 'path', or 'url' parameter")
+	}
+	if count > 1 {
+		return fmt.Errorf("only one of 'port', 'path', or 'url' may be specified")
+	}
+
+	// The durations should be valid.
+	for _, key := range []string{"timeout", "delay"} {
+		_, err := w.seconds(args, key, time.Second)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Execute is part of the module-api, and is invoked to run a rule.
+func (w *WaitModule) Execute(args map[string]interface{}) (bool, error) {
+
+	timeout, err := w.seconds(args, "timeout", 60*time.Second)
+	if err != nil {
+		return false, err
+	}
+	delay, err := w.seconds(args, "delay", time.Second)
+	if err != nil {
+		return false, err
+	}
+
+	// Work out what we're waiting for.
+	var desc string
+	var ready func() bool
+
+	switch {
+	case StringParam(args, "port") != "":
+		addr := StringParam(args, "port")
+		desc = "port " + addr
+		ready = func() bool {
+			conn, err := net.DialTimeout("tcp", addr, delay)
+			if err != nil {
+				return false
+			}
+			conn.Close()
+			return true
+		}
+
+	case StringParam(args, "path") != "":
+		path := StringParam(args, "path")
+		desc = "path " + path
+		ready = func() bool {
+			return file.Exists(path)
+		}
+
+	default:
+		url := StringParam(args, "url")
+		desc = "url " + url
+		client := http.Client{Timeout: delay}
+		ready = func() bool {
+			resp, err := client.Get(url)
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.StatusCode >= 200 && resp.StatusCode < 300
+		}
+	}
+
+	// Poll until we're ready, or we run out of time.
+	deadline := time.Now().Add(timeout)
+	for {
+		if ready() {
+			log.Printf("[DEBUG] %s is ready", desc)
+
+			// We're a barrier, so we never change anything.
+			return false, nil
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return false, fmt.Errorf("timed out after %s waiting for %s", timeout, desc)
+		}
+
+		log.Printf("[DEBUG] Waiting %s for %s", delay, desc)
+		time.Sleep(delay)
+	}
+}
+
+// seconds returns the named parameter as a number of seconds, or the
+// default if it was not specified.
+func (w *WaitModule) seconds(args map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+
+	val := StringParam(args, key)
+	if val == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("'%s' must be a positive number of seconds, got '%s'", key, val)
+	}
+
+	return time.Duration(n) * time.Second, nil
+}
+
+// init is used to dynamically register our module.
+func init() {
+	Register("wait_for", func(cfg *config.Config, env *environment.Environment) ModuleAPI {
+		return &WaitModule{
+			cfg: cfg,
+			env: env,
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package modules
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitCheck(t *testing.T) {
+
+	w := &WaitModule{}
+
+	args := make(map[string]interface{})
+
+	// Missing target
+	err := w.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to missing target")
+	}
+	if !strings.Contains(err.Error(), "missing 'port'") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+
+	// Too many targets
+	args["port"] = "localhost:22"
+	args["path"] = "/tmp/ready"
+	err = w.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to multiple targets")
+	}
+
+	// Bogus timeout
+	delete(args, "path")
+	args["timeout"] = "soon"
+	err = w.Check(args)
+	if err == nil {
+		t.Fatalf("expected error due to bogus timeout")
+	}
+
+	args["timeout"] = "10"
+	args["delay"] = "2"
+	err = w.Check(args)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestWaitExecute(t *testing.T) {
+
+	// Create a temporary directory to work within
+	dir, err := ioutil.TempDir("", "marionette-")
+	if err != nil {
+		t.Fatalf("create a temporary directory failed")
+	}
+	defer os.RemoveAll(dir)
+
+	// A listening port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	// A web-server which is ready on the second request
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	// A file which appears later
+	path := filepath.Join(dir, "ready")
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		ioutil.WriteFile(path, []byte("ready"), 0644)
+	}()
+
+	tests := []map[string]interface{}{
+		{"port": ln.Addr().String()},
+		{"url": ts.URL},
+		{"path": path, "timeout": "5"},
+	}
+
+	for _, args := range tests {
+
+		w := &WaitModule{}
+		changed, err := w.Execute(args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", args, err)
+		}
+		if changed {
+			t.Fatalf("wait_for should never report a change")
+		}
+	}
+
+	// Timeout
+	w := &WaitModule{}
+	args := map[string]interface{}{
+		"path":    filepath.Join(dir, "missing"),
+		"timeout": "1",
+	}
+	_, err = w.Execute(args)
+	if err == nil {
+		t.Fatalf("expected timeout")
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("got error - but wrong one : %s", err)
+	}
+}
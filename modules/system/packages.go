@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/google/shlex"
@@ -62,6 +63,15 @@ var (
 	}
 )
 
+// Track whether the package-lists have been updated during this run.
+var (
+	// updateMutex protects updated.
+	updateMutex sync.Mutex
+
+	// updated is true if an update has been carried out.
+	updated bool
+)
+
 // Package maintains our object state
 type Package struct {
 
@@ -143,6 +153,38 @@ func (p *Package) Update() error {
 	return p.run(run, env)
 }
 
+// UpdateOnce carries out the update command for a given system, unless
+// it has already been carried out successfully during this run.
+//
+// The return value reports whether an update was actually performed.
+func (p *Package) UpdateOnce() (bool, error) {
+
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	if updated {
+		log.Printf("[DEBUG] packages:UpdateOnce skipping update, already performed")
+		return false, nil
+	}
+
+	err := p.Update()
+	if err != nil {
+		return false, err
+	}
+
+	updated = true
+	return true, nil
+}
+
+// Invalidate records that the package-lists are out of date, for example
+// because a new repository was added, so the next call to UpdateOnce
+// will carry out a fresh update.
+func Invalidate() {
+	updateMutex.Lock()
+	updated = false
+	updateMutex.Unlock()
+}
+
 // IsInstalled checks a package installed?
 func (p *Package) IsInstalled(name string) (bool, error) {
 
@@ -0,0 +1,38 @@
+package system
+
+import (
+	"testing"
+)
+
+func TestUpdateOnce(t *testing.T) {
+
+	// An unknown system can't be updated.
+	p := &Package{}
+
+	Invalidate()
+	ran, err := p.UpdateOnce()
+	if err == nil {
+		t.Fatalf("expected error updating unknown system")
+	}
+	if ran {
+		t.Fatalf("update shouldn't have run")
+	}
+
+	// Pretend the update already ran.
+	updated = true
+
+	ran, err = p.UpdateOnce()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ran {
+		t.Fatalf("update shouldn't have run twice")
+	}
+
+	// Invalidating means we'll try again.
+	Invalidate()
+	_, err = p.UpdateOnce()
+	if err == nil {
+		t.Fatalf("expected error updating unknown system")
+	}
+}
@@ -9,9 +9,11 @@
 //
 //  2. Variable assignments.
 //
-//  3. File inclusions.
+//  3. File inclusions, of single files or directories.
 //
-//  4. AST nodes for various primitive types (strings, numbers, etc).
+//  4. Loops, which repeat the statements they contain.
+//
+//  5. AST nodes for various primitive types (strings, numbers, etc).
 //
 // Expansion of variables, handling of include-files, and command
 // execution for the case of variable assignments, will all happen
@@ -92,65 +94,55 @@ func (p *Parser) Parse() (ast.Program, error) {
 		}
 
 		// Now parse the various logical program-things we have.
-
-		// Is this an assignment?
-		if tok.Literal == "let" {
-
-			// Parse the assignment-statement
-			var let *ast.Assign
-			let, err = p.parseLet()
-			if err != nil {
-				return program, err
-			}
-
-			if p.debug {
-				fmt.Printf("%v\n", let)
-			}
-
-			// Add our rule onto the program, and continue
-			program.Recipe = append(program.Recipe, let)
-			continue
-		}
-
-		// Is this an include-file?
-		if tok.Literal == "include" {
-
-			// Parse the include-statement
-			var inc *ast.Include
-			inc, err = p.parseInclude()
-			if err != nil {
-				return program, err
-			}
-
-			if p.debug {
-				fmt.Printf("%v\n", inc)
-			}
-
-			// Add our rule onto the program, and continue
-			program.Recipe = append(program.Recipe, inc)
-			continue
-		}
-
-		// Otherwise it should be a block, which we need to parse.
-		var tmp *ast.Rule
-		tmp, err = p.parseBlock(tok.Literal)
+		var node ast.Node
+		node, err = p.parseStatement(tok)
 		if err != nil {
 			return program, err
 		}
 
 		// If we're debugging then show what we produced.
 		if p.debug {
-			fmt.Printf("%v\n", tmp)
+			fmt.Printf("%v\n", node)
 		}
 
-		// Add our rule onto the program, and continue
-		program.Recipe = append(program.Recipe, tmp)
+		// Add our statement onto the program, and continue
+		program.Recipe = append(program.Recipe, node)
 	}
 
 	// No error
 	return program, nil
 }
 
+// parseStatement parses a single statement, which begins with the given
+// token.
+//
+// This is used both at the top-level of our programs, and within the
+// body of foreach-loops.
+func (p *Parser) parseStatement(tok token.Token) (ast.Node, error) {
+
+	switch tok.Literal {
+
+	// Is this an assignment?
+	case "let":
+		return p.parseLet()
+
+	// Is this an include-file?
+	case "include":
+		return p.parseInclude()
+
+	// Is this a directory of include-files?
+	case "include_dir":
+		return p.parseIncludeDir()
+
+	// Is this a loop?
+	case "foreach":
+		return p.parseForeach()
+	}
+
+	// Otherwise it should be a block, which we need to parse.
+	return p.parseBlock(tok.Literal)
+}
+
 // parseLet parses an assignment statement
 func (p *Parser) parseLet() (*ast.Assign, error) {
 
@@ -183,12 +175,6 @@ func (p *Parser) parseLet() (*ast.Assign, error) {
 		return let, err
 	}
 
-	// Assignments won't handle arrays yet
-	_, ok := val.(ast.Array)
-	if ok {
-		return let, fmt.Errorf("you cannot assign an array to a variable")
-	}
-
 	let.Value = val
 
 	// Look at the next token and see if it is a
@@ -269,11 +255,98 @@ func (p *Parser) parseInclude() (*ast.Include, error) {
 
 }
 
+// parseIncludeDir parses an include_dir-statement.
+//
+// This has the same form as an include-statement, so we parse it
+// as one and convert the result.
+func (p *Parser) parseIncludeDir() (*ast.IncludeDir, error) {
+
+	inc, err := p.parseInclude()
+	if err != nil {
+		return &ast.IncludeDir{}, err
+	}
+
+	return &ast.IncludeDir{
+		Source:        inc.Source,
+		ConditionType: inc.ConditionType,
+		Function:      inc.Function,
+	}, nil
+}
+
+// parseForeach parses a foreach-loop.
+//
+// A loop has the general form:
+//
+//	foreach VARIABLE in [ "value1", "value2" ] {
+//	     statements
+//	}
+//
+// The statements within the body may be rules, assignments,
+// inclusions, or further loops.
+func (p *Parser) parseForeach() (*ast.Foreach, error) {
+
+	loop := &ast.Foreach{}
+
+	// name of the loop variable.
+	name := p.nextToken()
+	if name.Type != token.IDENT {
+		return loop, fmt.Errorf("foreach variable must be an identifier, got %v", name)
+	}
+	loop.Variable = name.Literal
+
+	// "in"
+	t := p.nextToken()
+	if t.Literal != "in" {
+		return loop, fmt.Errorf("expected 'in' after foreach variable, got %v", t)
+	}
+
+	// The values to iterate over.
+	t = p.nextToken()
+	obj, err := p.parsePrimitive(t)
+	if err != nil {
+		return loop, err
+	}
+	array, ok := obj.(ast.Array)
+	if !ok {
+		return loop, fmt.Errorf("foreach requires an array of values, got %v", obj)
+	}
+	loop.Values = array
+
+	// "{"
+	t = p.nextToken()
+	if t.Type != token.LBRACE {
+		return loop, fmt.Errorf("expected '{', got %v", t)
+	}
+
+	// Now parse statements until we find the end of the body.
+	for {
+		t = p.nextToken()
+
+		if t.Type == token.ILLEGAL {
+			return loop, fmt.Errorf("illegal token: %v", t)
+		}
+		if t.Type == token.EOF {
+			return loop, fmt.Errorf("unexpected EOF in foreach body")
+		}
+		if t.Type == token.RBRACE {
+			break
+		}
+
+		node, err := p.parseStatement(t)
+		if err != nil {
+			return loop, err
+		}
+		loop.Body = append(loop.Body, node)
+	}
+
+	return loop, nil
+}
+
 // parseBlock parses the contents of modules' block.
 //
 // A block has the general form:
 //
-//  type [triggered] {
+//  type [triggered|at_exit] {
 //       key1   => "value",
 //       key2   => [ "foo", "bar", "baz" ],
 //       unless => expression(),
@@ -294,7 +367,7 @@ func (p *Parser) parseBlock(ty string) (*ast.Rule, error) {
 	r.Params = make(map[string]interface{})
 	r.Type = ty
 
-	// We should find either "triggered" or "{".
+	// We should find either "triggered", "at_exit", or "{".
 	t := p.nextToken()
 	if t.Literal == "triggered" {
 
@@ -302,6 +375,12 @@ func (p *Parser) parseBlock(ty string) (*ast.Rule, error) {
 		// record that and skip to the next token
 		r.Triggered = true
 		t = p.nextToken()
+	} else if t.Literal == "at_exit" {
+
+		// OK it is a rule to run at exit.
+		// record that and skip to the next token
+		r.AtExit = true
+		t = p.nextToken()
 	}
 
 	// "{"
@@ -418,9 +497,35 @@ func (p *Parser) parseBlock(ty string) (*ast.Rule, error) {
 	// generate one.
 	r.Name = p.getName(r.Params)
 
+	// Save the description, if there is one.
+	r.Description = p.getDescription(r.Params)
+
 	return r, nil
 }
 
+// getDescription returns the description from the specified map, if one
+// wasn't set then we return an empty string.
+func (p *Parser) getDescription(params map[string]interface{}) string {
+
+	// Did we get a description parameter?
+	d, ok := params["description"]
+	if !ok {
+		return ""
+	}
+
+	// OK we did.  Was it a string?
+	str, ok := d.(ast.String)
+	if ok {
+		return str.Value
+	}
+
+	// Show a warning.
+	if p.debug {
+		fmt.Printf("WARNING: Description of rule is not ast.String, got %T\n", d)
+	}
+	return ""
+}
+
 // getName returns the name from the specified map, if one wasn't
 // set then we return a random UUID.
 func (p *Parser) getName(params map[string]interface{}) string {
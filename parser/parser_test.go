@@ -56,6 +56,7 @@ func TestAssignment(t *testing.T) {
 		"let x = `/bin/true` if equal(\"a\",\"a\")",
 		"let a = \"boo\"",
 		"let _false_ = \"ok\"",
+		"let pkgs = [ \"git\", \"curl\" ]",
 		"let _true_like = \"ok\"",
 	}
 
@@ -113,6 +114,7 @@ func TestBlock(t *testing.T) {
 	// valid tests
 	valid := []string{`file { target => "steve", name => "steve" }`,
 		`moi triggered { test => "steve", name => "steve" }`,
+		`moi at_exit { test => "steve", name => "steve" }`,
 		`foo { name => [ "one", "two", ] }`,
 	}
 
@@ -270,6 +272,135 @@ func TestInclude(t *testing.T) {
 	}
 }
 
+// TestIncludeDir tests that include_dir statements are parsed.
+func TestIncludeDir(t *testing.T) {
+
+	// Broken statements
+	broken := []string{
+		"include_dir",
+		"include_dir \"conf.d\" if true(/bin/ls",
+	}
+
+	for _, test := range broken {
+		p := New(test)
+		_, err := p.Parse()
+		if err == nil {
+			t.Errorf("expected error parsing broken include_dir '%s' - got none", test)
+		}
+	}
+
+	// Valid statements
+	valid := []string{
+		"include_dir \"conf.d\"",
+		"include_dir [ \"conf.d\", \"local.d\" ]",
+		"include_dir \"conf.d\" if exists(\"conf.d\")",
+	}
+
+	for _, test := range valid {
+		p := New(test)
+		out, err := p.Parse()
+		if err != nil {
+			t.Errorf("unexpected error parsing include_dir '%s': %s", test, err)
+			continue
+		}
+		if len(out.Recipe) != 1 {
+			t.Fatalf("unexpected number of results")
+		}
+		if _, ok := out.Recipe[0].(*ast.IncludeDir); !ok {
+			t.Errorf("expected IncludeDir, got %T", out.Recipe[0])
+		}
+	}
+}
+
+// TestForeach tests that foreach-loops are parsed.
+func TestForeach(t *testing.T) {
+
+	// Broken statements
+	broken := []string{
+		"foreach",
+		"foreach \"host\" in [ \"a\" ] { }",
+		"foreach host [ \"a\" ] { }",
+		"foreach host in \"a\" { }",
+		"foreach host in [ \"a\" ] shell",
+		"foreach host in [ \"a\" ] { shell { command => \"id\" }",
+		"foreach host in [ \"a\" ] { let = }",
+	}
+
+	for _, test := range broken {
+		p := New(test)
+		_, err := p.Parse()
+		if err == nil {
+			t.Errorf("expected error parsing broken foreach '%s' - got none", test)
+		}
+	}
+
+	input := `
+foreach host in [ "a", "b" ] {
+   let path = "/etc/${host}.conf"
+   file { target => "${path}", content => "${host}" }
+   foreach i in [ "1", "2" ] {
+      log { message => "${host}${i}" }
+   }
+}
+`
+	p := New(input)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error parsing foreach: %s", err)
+	}
+	if len(out.Recipe) != 1 {
+		t.Fatalf("unexpected number of results")
+	}
+
+	loop, ok := out.Recipe[0].(*ast.Foreach)
+	if !ok {
+		t.Fatalf("expected Foreach, got %T", out.Recipe[0])
+	}
+	if loop.Variable != "host" {
+		t.Errorf("wrong variable: %s", loop.Variable)
+	}
+	if len(loop.Values.Values) != 2 {
+		t.Errorf("wrong number of values: %d", len(loop.Values.Values))
+	}
+	if len(loop.Body) != 3 {
+		t.Errorf("wrong number of statements in body: %d", len(loop.Body))
+	}
+	if !strings.Contains(loop.String(), "Variable:host") {
+		t.Errorf("unexpected string: %s", loop.String())
+	}
+}
+
+// Test that rules may have a description.
+func TestDescription(t *testing.T) {
+
+	input := `
+shell { command => "id", description => "Show our identity" }
+shell { command => "id", description => [ "not", "a", "string" ] }
+shell { command => "id" }
+`
+	p := New(input)
+	out, err := p.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error parsing input '%s': %s", input, err.Error())
+	}
+
+	expected := []string{"Show our identity", "", ""}
+	for i, desc := range expected {
+		rule, ok := out.Recipe[i].(*ast.Rule)
+		if !ok {
+			t.Fatalf("expected rule, got %T", out.Recipe[i])
+		}
+		if rule.Description != desc {
+			t.Errorf("wrong description for rule %d: '%s'", i, rule.Description)
+		}
+	}
+
+	rule := out.Recipe[0].(*ast.Rule)
+	if !strings.Contains(rule.String(), "Description:Show our identity") {
+		t.Errorf("unexpected string: %s", rule.String())
+	}
+}
+
 // #86 - Test we can parse modules without spaces
 func TestModuleSpace(t *testing.T) {
 